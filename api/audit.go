@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+)
+
+func (s *Server) listAudit() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		meta, entries, err := s.store(r).Audit().List(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-audit", err)
+			return
+		}
+
+		var jsonEntries = make([]formator.JsonData, len(entries))
+		for i, e := range entries {
+			jsonEntries[i] = formator.NewJSONData("audit", e.ID.Hex(), api_apbp.MapAuditEntryToJSON(e))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonEntries,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
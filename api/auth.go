@@ -1,10 +1,22 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/session"
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/reset"
+	sessionrepo "github.com/valensto/api_apbp/infra/repo/session"
+	"github.com/valensto/api_apbp/infra/repo/user"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,14 +27,6 @@ func (s *Server) login() http.HandlerFunc {
 		Password string `json:"password" validate:"required"`
 	}
 
-	type token struct {
-		Token string `json:"token"`
-	}
-
-	type response struct {
-		Data token `json:"data"`
-	}
-
 	return func(w http.ResponseWriter, r *http.Request) {
 		req := request{}
 		err := s.decode(w, r, &req)
@@ -40,14 +44,13 @@ func (s *Server) login() http.HandlerFunc {
 			s.respondErr(w, r, http.StatusInternalServerError, "auth-validation-json", err)
 		}
 
-		u, err := s.Store.User().FindByCredential(req.Email)
+		u, err := s.store(r).User().FindByCredential(req.Email)
 		if err != nil {
 			s.respond(w, r, http.StatusNotFound, nil)
 			return
 		}
 
-		// TODO not proud
-		if u.Role != "admin" {
+		if u.Password == "" {
 			s.respond(w, r, http.StatusNotFound, nil)
 			return
 		}
@@ -58,22 +61,242 @@ func (s *Server) login() http.HandlerFunc {
 			return
 		}
 
-		// TODO isAdmin
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"userID":  u.ID.Hex(),
-			"isAdmin": true,
-			"exp":     time.Now().Add(time.Hour * time.Duration(24)).Unix(),
-			"iat":     time.Now().Unix(),
-		})
+		if err := s.issueTokens(w, u, primitive.NewObjectID()); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "generate-jwt", err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *Server) refresh() http.HandlerFunc {
+
+	type request struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-request", err)
+			return
+		}
+
+		respErr, err := s.validateStruct(r, req)
+		if len(respErr) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "auth-validation-json", respErr)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "auth-validation-json", err)
+			return
+		}
+
+		old, err := s.store(r).Session().Rotate(hashToken(req.RefreshToken))
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "rotating-session", err)
+			return
+		}
 
-		tokenStr, err := token.SignedString([]byte(s.Conf.JWTSecret))
+		u, err := s.store(r).User().Read(old.User.Hex())
 		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "retrieving-user", err)
+			return
+		}
+
+		if err := s.issueTokens(w, u, old.Family); err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "generate-jwt", err)
 			return
 		}
 
-		w.Header().Add("x-auth-token", tokenStr)
-		w.Header().Add("Access-Control-Expose-Headers", "x-auth-token")
 		s.respond(w, r, http.StatusNoContent, nil)
 	}
 }
+
+func (s *Server) logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := session.GetClaims(r.Context())
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		if err := s.store(r).Session().RevokeByJTI(claims.JTI); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "revoking-session", err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *Server) forgotPwd() http.HandlerFunc {
+
+	type request struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-request", err)
+			return
+		}
+
+		respErr, err := s.validateStruct(r, req)
+		if len(respErr) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "auth-validation-json", respErr)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "auth-validation-json", err)
+			return
+		}
+
+		// the response is the same whether the email exists or not
+		u, err := s.store(r).User().FindByCredential(req.Email)
+		if err == nil && u.Email != "" {
+			go func() {
+				token, err := randomToken(32)
+				if err != nil {
+					log.Println(err)
+					return
+				}
+
+				now := time.Now()
+				err = s.Store.Reset().Create(reset.Reset{
+					ID:        primitive.NewObjectID(),
+					User:      u.ID,
+					TokenHash: hashToken(token),
+					CreatedAt: now,
+					ExpiresAt: now.Add(s.Conf.ResetTTL),
+				})
+				if err != nil {
+					log.Println(err)
+					return
+				}
+
+				mail := api_apbp.MapUserToJSON(u).ResetPasswordMail(token, s.Conf.ResetURL)
+				if err := s.Mailer.Send(mail); err != nil {
+					log.Println(err)
+				}
+			}()
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+func (s *Server) resetPwd() http.HandlerFunc {
+
+	type request struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"newpwd" validate:"required,pwd"`
+		VerifPwd string `json:"verifpwd" validate:"required,eqfield=Password"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-request", err)
+			return
+		}
+
+		respErr, err := s.validateStruct(r, req)
+		if len(respErr) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "auth-validation-json", respErr)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "auth-validation-json", err)
+			return
+		}
+
+		rs, err := s.store(r).Reset().Consume(hashToken(req.Token))
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "consuming-reset", err)
+			return
+		}
+
+		pwd, err := user.HashPassword(req.Password)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "hashing-password", err)
+			return
+		}
+
+		_, err = s.store(r).User().UpdateField(rs.User.Hex(), "password", pwd, repo.AnyVersion)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-user", err)
+			return
+		}
+
+		if err := s.store(r).Session().RevokeUser(rs.User); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "revoking-session", err)
+			return
+		}
+
+		s.respond(w, r, http.StatusNoContent, nil)
+	}
+}
+
+// issueTokens persist a new session of the family and write access and refresh tokens headers
+func (s *Server) issueTokens(w http.ResponseWriter, u user.User, family primitive.ObjectID) error {
+	jti, err := randomToken(16)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userID": u.ID.Hex(),
+		"role":   u.Role,
+		"jti":    jti,
+		"exp":    now.Add(s.Conf.AccessTTL).Unix(),
+		"iat":    now.Unix(),
+	})
+
+	tokenStr, err := token.SignedString([]byte(s.Conf.JWTSecret))
+	if err != nil {
+		return err
+	}
+
+	err = s.Store.Session().Create(sessionrepo.Session{
+		ID:        primitive.NewObjectID(),
+		Family:    family,
+		User:      u.ID,
+		JTI:       jti,
+		TokenHash: hashToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.Conf.RefreshTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("x-auth-token", tokenStr)
+	w.Header().Add("x-refresh-token", refreshToken)
+	w.Header().Add("Access-Control-Expose-Headers", "x-auth-token, x-refresh-token")
+	return nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/infra/repo/category"
+	"github.com/valensto/api_apbp/infra/store"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"github.com/valensto/api_apbp/pkg/slug"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Server) listCategory() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		meta, categories, err := s.store(r).Category().List(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-categories", err)
+			return
+		}
+
+		var jsonCategories = make([]formator.JsonData, len(categories))
+		for i, c := range categories {
+			jsonCategories[i] = formator.NewJSONData("categories", c.ID.Hex(), api_apbp.MapCategoryToJSON(&c))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonCategories,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) listCatalog() http.HandlerFunc {
+	type response struct {
+		Data []formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+
+		catalog, err := s.store(r).Product().Catalog(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-catalog", err)
+			return
+		}
+
+		var jsonCatalog = make([]formator.JsonData, len(catalog))
+		for i, c := range catalog {
+			id := ""
+			if c.Category != nil {
+				id = c.Category.ID.Hex()
+			}
+			jsonCatalog[i] = formator.NewJSONData("catalog", id, api_apbp.MapCatalogToJSON(&c))
+		}
+
+		resp := response{
+			Data: jsonCatalog,
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) getCategory() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		c, err := s.store(r).Category().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-category", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("categories", c.ID.Hex(), api_apbp.MapCategoryToJSON(&c)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) createCategory() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := api_apbp.JsonCategory{}
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-category", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "category-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "category-json-validation", err)
+			return
+		}
+
+		now := time.Now()
+		c := categoryFromJSON(req)
+		c.ID = primitive.NewObjectID()
+		c.CreatedAt = now
+		c.ModifiedAt = now
+
+		if err := checkCategory(s.store(r), c); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-category", err)
+			return
+		}
+
+		err = s.store(r).Category().Create(c)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "creating-category", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("categories", c.ID.Hex(), api_apbp.MapCategoryToJSON(&c)),
+		}
+		s.respond(w, r, http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) updateCategory() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+		req := api_apbp.JsonCategory{}
+
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-category", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "category-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "category-json-validation", err)
+			return
+		}
+
+		current, err := s.store(r).Category().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-category", err)
+			return
+		}
+
+		upd := categoryFromJSON(req)
+		upd.ID = current.ID
+		if err := checkCategory(s.store(r), upd); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-category", err)
+			return
+		}
+
+		c, err := s.store(r).Category().UpdateFields(id, upd)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-category", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("categories", c.ID.Hex(), api_apbp.MapCategoryToJSON(&c)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) deleteCategory() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+		st := s.store(r)
+
+		c, err := st.Category().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-category", err)
+			return
+		}
+
+		n, err := st.Product().InCategory(c.ID)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "counting-products", err)
+			return
+		}
+		if n > 0 {
+			s.respondErr(w, r, http.StatusConflict, "deleting-category", fmt.Errorf("category %v still has %d products", c.Slug, n))
+			return
+		}
+
+		children, err := st.Category().Children(c.ID)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-categories", err)
+			return
+		}
+		if len(children) > 0 {
+			s.respondErr(w, r, http.StatusConflict, "deleting-category", fmt.Errorf("category %v still has %d sub categories", c.Slug, len(children)))
+			return
+		}
+
+		if err := st.Category().Delete(id); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "deleting-category", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("categories", id, nil),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// categoryFromJSON build a category, the slug defaults to the slug of the name and categories are visible unless told otherwise
+func categoryFromJSON(js api_apbp.JsonCategory) category.Category {
+	c := category.Category{
+		Slug:        js.Slug,
+		Name:        js.Name,
+		Description: js.Description,
+		Position:    js.Position,
+		Parent:      js.Parent,
+		Visible:     true,
+	}
+	if c.Slug == "" {
+		c.Slug = slug.Make(js.Name)
+	}
+	if js.Visible != nil {
+		c.Visible = *js.Visible
+	}
+	return c
+}
+
+// checkCategory ensure the slug is well formed and the parent exists without making a cycle
+func checkCategory(st store.Store, c category.Category) error {
+	if !slug.Valid(c.Slug) {
+		return fmt.Errorf("slug %q must be lowercase letters and digits separated by dashes", c.Slug)
+	}
+
+	seen := map[primitive.ObjectID]bool{c.ID: true}
+	for parent := c.Parent; parent != nil; {
+		if seen[*parent] {
+			return fmt.Errorf("category %v can't be its own ancestor", c.Slug)
+		}
+		seen[*parent] = true
+
+		p, err := st.Category().Read(parent.Hex())
+		if err != nil {
+			return fmt.Errorf("parent category %v not found", parent.Hex())
+		}
+		parent = p.Parent
+	}
+	return nil
+}
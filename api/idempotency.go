@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/api/session"
+	"github.com/valensto/api_apbp/infra/repo/idempotency"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of the response written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent replays the response of a request already made by the user with the same
+// Idempotency-Key header and rejects a key reused for a different request
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		if len(key) > 255 {
+			s.respondErr(w, r, http.StatusBadRequest, "parsing-idempotency-key", fmt.Errorf("%v must not exceed 255 characters", idempotencyHeader))
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-json", err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		uid, _ := session.GetUserID(r.Context())
+		hash := hashToken(r.Method + " " + r.URL.Path + "\n" + string(body))
+		now := time.Now()
+
+		ks := s.store(r).Idempotency()
+		k, reserved, err := ks.Reserve(idempotency.Key{
+			User:          uid,
+			Key:           key,
+			RequestHash:   hash,
+			CreatedAt:     now,
+			ReservedUntil: now.Add(s.Conf.IdempotencyLease),
+			ExpiresAt:     now.Add(s.Conf.IdempotencyTTL),
+		})
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reserving-idempotency-key", err)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case k.RequestHash != hash:
+				s.respondErr(w, r, http.StatusUnprocessableEntity, "reusing-idempotency-key", fmt.Errorf("%v was already used for a different request", idempotencyHeader))
+			case k.Status == 0:
+				s.respondErr(w, r, http.StatusConflict, "reusing-idempotency-key", fmt.Errorf("a request with this %v is still processed", idempotencyHeader))
+			default:
+				w.Header().Add("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(k.Status)
+				w.Write(k.Body)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		// only successes are replayed, a failed request can be retried with the same key
+		if rec.status >= 200 && rec.status < 300 {
+			err = ks.Complete(k, rec.status, rec.body.Bytes())
+		} else {
+			err = ks.Release(k)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
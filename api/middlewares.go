@@ -19,8 +19,8 @@ func (s *Server) commonMW() {
 		AllowedOrigins: []string{"*"},
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Link", "ETag", "Idempotent-Replayed", "x-auth-token", "x-refresh-token"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -53,7 +53,27 @@ func (s *Server) restricted(next http.HandlerFunc) http.HandlerFunc {
 			}
 
 			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				ctx := session.WithUserID(r.Context(), claims["userID"].(string))
+				uid, _ := claims["userID"].(string)
+				role, _ := claims["role"].(string)
+				jti, _ := claims["jti"].(string)
+
+				if jti == "" {
+					s.respond(w, r, http.StatusUnauthorized, nil)
+					return
+				}
+
+				revoked, err := s.store(r).Session().IsRevoked(jti)
+				if err != nil || revoked {
+					s.respond(w, r, http.StatusUnauthorized, nil)
+					return
+				}
+
+				ctx := session.WithUserID(r.Context(), uid)
+				ctx = session.WithClaims(ctx, session.Claims{
+					JTI:         jti,
+					Role:        role,
+					Permissions: s.Conf.Roles[role],
+				})
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -62,3 +82,37 @@ func (s *Server) restricted(next http.HandlerFunc) http.HandlerFunc {
 		s.respond(w, r, http.StatusUnauthorized, nil)
 	}
 }
+
+// require restricts access to authenticated users whose role grants the permission
+func (s *Server) require(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return s.restricted(func(w http.ResponseWriter, r *http.Request) {
+		if !s.can(r, perm) {
+			s.respondErr(w, r, http.StatusForbidden, "missing-permission", fmt.Errorf("permission %v is required", perm))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) can(r *http.Request, perm string) bool {
+	claims, err := session.GetClaims(r.Context())
+	return err == nil && claims.Can(perm)
+}
+
+// authorize checks that the session user has the permission or is the owner of the resource
+func (s *Server) authorize(r *http.Request, perm, owner string) error {
+	if s.can(r, perm) {
+		return nil
+	}
+
+	uid, err := session.GetUserID(r.Context())
+	if err != nil {
+		return err
+	}
+
+	if uid != owner {
+		return errForbidden
+	}
+	return nil
+}
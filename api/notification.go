@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+)
+
+func (s *Server) listNotifications() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		meta, events, err := s.store(r).Outbox().List(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-notifications", err)
+			return
+		}
+
+		var jsonEvents = make([]formator.JsonData, len(events))
+		for i, e := range events {
+			jsonEvents[i] = formator.NewJSONData("notifications", e.ID.Hex(), api_apbp.MapNotificationToJSON(e))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonEvents,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
@@ -4,14 +4,22 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/valensto/api_apbp"
 	"github.com/valensto/api_apbp/api/formator"
 	"github.com/valensto/api_apbp/api/session"
 	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/infra/repo/outbox"
+	"github.com/valensto/api_apbp/infra/repo/product"
+	"github.com/valensto/api_apbp/infra/repo/stock"
+	"github.com/valensto/api_apbp/infra/store"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
+	"github.com/valensto/api_apbp/pkg/ref"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -24,8 +32,24 @@ func (s *Server) listOrder() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		f := filter.ParseQuery(r.URL.RequestURI())
+		f.WithDeleted = f.WithDeleted && s.can(r, "orders:admin")
 
-		meta, orders, err := s.Store.Order().List(f)
+		customer := primitive.NilObjectID
+		if !s.can(r, "orders:admin") {
+			uIDstr, err := session.GetUserID(r.Context())
+			if err != nil {
+				s.respondErr(w, r, http.StatusUnauthorized, "", err)
+				return
+			}
+
+			customer, err = primitive.ObjectIDFromHex(uIDstr)
+			if err != nil {
+				s.respondErr(w, r, http.StatusBadRequest, "decoding-customer", err)
+				return
+			}
+		}
+
+		meta, orders, err := s.store(r).Order().List(f, customer)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "listing-order", err)
 			return
@@ -40,7 +64,7 @@ func (s *Server) listOrder() http.HandlerFunc {
 		resp := response{
 			Meta:  meta,
 			Data:  jsonOrders,
-			Links: f.Pagination.GetLinks(r.URL.RequestURI(), meta.TotalElements),
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
 		}
 
 		s.respond(w, r, http.StatusOK, resp)
@@ -53,6 +77,12 @@ func (s *Server) forecast(confirm bool) http.HandlerFunc {
 		Data []api_apbp.JsonForecast `json:"data"`
 	}
 
+	type seriesResponse struct {
+		Meta    map[string]time.Time          `json:"meta"`
+		GroupBy string                        `json:"group_by"`
+		Data    []api_apbp.JsonForecastBucket `json:"data"`
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		f := filter.ParseQuery(r.URL.RequestURI())
 
@@ -62,7 +92,32 @@ func (s *Server) forecast(confirm bool) http.HandlerFunc {
 			return
 		}
 
-		fs, err := s.Store.Order().Forecast(f, confirm)
+		meta := map[string]time.Time{
+			"from": f.Range.Start,
+			"to":   f.Range.End,
+		}
+
+		if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+			fbs, err := s.store(r).Order().ForecastSeries(f, confirm, groupBy)
+			if err != nil {
+				s.respondErr(w, r, http.StatusInternalServerError, "listing-forecast", err)
+				return
+			}
+
+			var jsonBuckets = make([]api_apbp.JsonForecastBucket, len(fbs))
+			for i, fb := range fbs {
+				jsonBuckets[i] = api_apbp.MapForecastBucketToJson(fb)
+			}
+
+			s.respond(w, r, http.StatusOK, seriesResponse{
+				Meta:    meta,
+				GroupBy: groupBy,
+				Data:    jsonBuckets,
+			})
+			return
+		}
+
+		fs, err := s.store(r).Order().Forecast(f, confirm)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "listing-forecast", err)
 			return
@@ -73,10 +128,6 @@ func (s *Server) forecast(confirm bool) http.HandlerFunc {
 			jsonForecasts[i] = api_apbp.MapForecastToJson(f)
 		}
 
-		meta := map[string]time.Time{
-			"from": f.Range.Start,
-			"to":   f.Range.End,
-		}
 		resp := response{
 			Meta: meta,
 			Data: jsonForecasts,
@@ -86,6 +137,55 @@ func (s *Server) forecast(confirm bool) http.HandlerFunc {
 	}
 }
 
+func (s *Server) preparationSheet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+
+		t := time.Time{}
+		if f.Range.End == t {
+			s.respondErr(w, r, http.StatusBadRequest, "parsing-params", fmt.Errorf("end param is a required param to ended request range, ?end=2006-01-02T15:04:05.000Z"))
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "html"
+		}
+		if format != "html" && format != "pdf" {
+			s.respondErr(w, r, http.StatusBadRequest, "parsing-params", fmt.Errorf("format must be one of html or pdf. got=%v", format))
+			return
+		}
+
+		os, err := s.store(r).Order().Preparation(*f.Range)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-preparation", err)
+			return
+		}
+
+		sheet := api_apbp.NewPreparationSheet(f.Range.Start, f.Range.End, os)
+
+		render, contentType := sheet.HTML, "text/html; charset=utf-8"
+		if format == "pdf" {
+			render, contentType = sheet.PDF, "application/pdf"
+		}
+
+		buf, err := render()
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "rendering-preparation", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if format == "pdf" {
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="preparation-%s.pdf"`, f.Range.Start.Format("2006-01-02")))
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := buf.WriteTo(w); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
 func (s *Server) getOrder() http.HandlerFunc {
 
 	type response struct {
@@ -96,35 +196,89 @@ func (s *Server) getOrder() http.HandlerFunc {
 		id := s.getParam(r, "id")
 		populate := r.URL.Query().Get("populate") == "1"
 
-		order, err := s.Store.Order().Read(id, populate)
+		order, err := s.store(r).Order().Read(id, populate)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "reading-order", err)
 			return
 		}
 
+		if err := s.authorize(r, "orders:admin", order.RelationShip.Customer.Hex()); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", err)
+			return
+		}
+
 		data := api_apbp.MapOrderToJSON(order)
 
 		resp := response{
 			Data: formator.NewJSONData("orders", order.ID.Hex(), data),
 		}
 
+		s.setETag(w, order.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
 
+func (s *Server) getOrderByRef() http.HandlerFunc {
+
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderRef := s.getParam(r, "ref")
+		populate := r.URL.Query().Get("populate") == "1"
+
+		// legacy refs have no prefix, only prefixed ones carry a check digit
+		if strings.HasPrefix(orderRef, s.Conf.OrderRefPrefix+"-") && !ref.Valid(s.Conf.OrderRefPrefix, orderRef) {
+			s.respondErr(w, r, http.StatusBadRequest, "invalid-ref", fmt.Errorf("ref %v has an invalid check digit", orderRef))
+			return
+		}
+
+		order, err := s.store(r).Order().ReadByRef(orderRef, populate)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-order", err)
+			return
+		}
+
+		if err := s.authorize(r, "orders:admin", order.RelationShip.Customer.Hex()); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", err)
+			return
+		}
+
+		data := api_apbp.MapOrderToJSON(order)
+
+		resp := response{
+			Data: formator.NewJSONData("orders", order.ID.Hex(), data),
+		}
+
+		s.setETag(w, order.Version)
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// nextOrderRef draw the next daily sequence number and format it as an order reference
+func (s *Server) nextOrderRef(st store.Store, now time.Time) (string, error) {
+	n, err := st.Counter().Next("orders:" + now.Format("2006-01-02"))
+	if err != nil {
+		return "", err
+	}
+	return ref.Format(s.Conf.OrderRefPrefix, now, n), nil
+}
+
 func (s *Server) createOrder() http.HandlerFunc {
 
 	type reqProductLine struct {
 		Quantity  float32            `json:"quantity,omitempty" validate:"required"`
 		Unit      string             `json:"unit,omitempty" validate:"required,oneof=gr p"`
 		ProductID primitive.ObjectID `json:"product_id,omitempty" validate:"required"`
+		Option    string             `json:"option,omitempty"`
 	}
 
 	type request struct {
 		RecoveryAt    time.Time          `json:"recovery_at,omitempty" validate:"required"`
 		Customer      primitive.ObjectID `json:"customer,omitempty" validate:"required"`
 		ProductsLines []reqProductLine   `json:"products,omitempty" validate:"required,unique,min=1,dive,required"`
-		Status        string             `json:"status,omitempty" validate:"required,oneof=waiting confirm ready delivered"`
+		Status        string             `json:"status,omitempty" validate:"required,oneof=waiting confirm"`
 	}
 
 	type response struct {
@@ -161,7 +315,17 @@ func (s *Server) createOrder() http.HandlerFunc {
 			return
 		}
 
-		customer, err := s.Store.User().Read(req.Customer.Hex())
+		if err := s.authorize(r, "orders:admin", req.Customer.Hex()); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", err)
+			return
+		}
+
+		if !s.can(r, "orders:admin") && req.Status != order.StatusWaiting {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", fmt.Errorf("customers can only create %v orders", order.StatusWaiting))
+			return
+		}
+
+		customer, err := s.store(r).User().Read(req.Customer.Hex())
 		if err != nil {
 			s.respondErr(w, r, 0, "", err)
 			return
@@ -169,53 +333,77 @@ func (s *Server) createOrder() http.HandlerFunc {
 
 		productLines := make([]order.ProductLine, len(req.ProductsLines))
 		for i, pl := range req.ProductsLines {
-			product, err := s.Store.Product().Read(pl.ProductID.Hex())
+			product, err := s.store(r).Product().Read(pl.ProductID.Hex())
 			if err != nil {
 				s.respondErr(w, r, http.StatusBadRequest, "product-not-found", err)
 				return
 			}
-			productLines[i] = order.ProductLine{
-				Quantity: pl.Quantity,
-				Unit:     pl.Unit,
-				Ref:      product.Ref,
-				Name:     product.Name,
-				AUW:      product.AUW,
+			productLines[i], err = orderLine(s.store(r), product, pl.Quantity, pl.Unit, pl.Option)
+			if err != nil {
+				s.respondErr(w, r, http.StatusBadRequest, "option-not-found", err)
+				return
 			}
 		}
 
+		if err := order.CheckPrices(productLines); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-prices", err)
+			return
+		}
+
+		totals := order.ComputeTotals(productLines)
+		now := time.Now()
+		orderRef, err := s.nextOrderRef(s.store(r), now)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "generating-ref", err)
+			return
+		}
+
 		o := order.Order{
 			ID:         primitive.NewObjectID(),
-			Ref:        api_apbp.GenerateRef(),
-			CreatedAt:  time.Now(),
-			ModifiedAt: time.Now(),
+			Ref:        orderRef,
+			CreatedAt:  now,
+			ModifiedAt: now,
 			RecoveryAt: req.RecoveryAt,
 			RelationShip: order.RelationShip{
 				Customer: customer.ID,
 				Editor:   uid,
 			},
 			ProductsLines: productLines,
+			Totals:        &totals,
 			Status:        req.Status,
+			StatusHistory: []order.StatusChange{{
+				To:     req.Status,
+				Editor: uid,
+				At:     now,
+			}},
 		}
 
-		err = s.Store.Order().Create(o)
-		if err != nil {
-			s.respondErr(w, r, http.StatusInternalServerError, "creating-order", err)
+		if err := s.bookSlot(o.RecoveryAt, o.Grams(), o.ID); err != nil {
+			s.respondErr(w, r, slotStatus(err), "booking-slot", err)
 			return
 		}
 
-		order := api_apbp.MapOrderToJSON(o)
+		if customer.Email != "" {
+			o.Events = []string{outbox.KindOrderCreated}
+		}
 
-		go func() {
-			if customer.Email != "" {
-				mail := order.NewOrderMail(customer)
-				if err := s.Mailer.Send(mail); err != nil {
-					log.Println(err)
-				}
-			}
-		}()
+		movements := orderMovements(o, "", o.Status, uid)
+		if err := s.applyMovements(movements); err != nil {
+			s.shiftSlot(o.RecoveryAt, -1, -o.Grams())
+			s.respondErr(w, r, http.StatusInternalServerError, "reserving-stock", err)
+			return
+		}
+
+		err = s.store(r).Order().Create(o)
+		if err != nil {
+			s.revertMovements(movements)
+			s.shiftSlot(o.RecoveryAt, -1, -o.Grams())
+			s.respondErr(w, r, http.StatusInternalServerError, "creating-order", err)
+			return
+		}
 
 		resp := response{
-			Data: formator.NewJSONData("orders", o.ID.Hex(), order),
+			Data: formator.NewJSONData("orders", o.ID.Hex(), api_apbp.MapOrderToJSON(o)),
 		}
 		s.respond(w, r, http.StatusCreated, resp)
 	}
@@ -223,7 +411,7 @@ func (s *Server) createOrder() http.HandlerFunc {
 
 func (s *Server) updateOrderStatus() http.HandlerFunc {
 	type request struct {
-		Status string `json:"status,omitempty" validate:"required,oneof=waiting confirm ready delivered"`
+		Status string `json:"status,omitempty" validate:"required,oneof=waiting confirm ready delivered cancelled"`
 	}
 
 	type response struct {
@@ -232,10 +420,22 @@ func (s *Server) updateOrderStatus() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := s.getParam(r, "id")
-		os := s.Store.Order()
+		os := s.store(r).Order()
 		req := request{}
 
-		err := s.decode(w, r, &req)
+		uIDstr, err := session.GetUserID(r.Context())
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		editor, err := primitive.ObjectIDFromHex(uIDstr)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-editor", err)
+			return
+		}
+
+		err = s.decode(w, r, &req)
 		if err != nil {
 			s.respondErr(w, r, http.StatusBadRequest, "decoding-json", err)
 			return
@@ -251,26 +451,57 @@ func (s *Server) updateOrderStatus() http.HandlerFunc {
 			return
 		}
 
-		o, err := os.UpdateField(id, "status", req.Status)
+		current, err := s.authorizeOrder(r, id)
 		if err != nil {
-			s.respondErr(w, r, http.StatusInternalServerError, "updating-order", err)
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", err)
 			return
 		}
 
-		order := api_apbp.MapOrderToJSON(o)
+		version, err := s.expectVersion(r, current.Version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
 
-		go func() {
-			if order.RelationShip.Included.Customer.Email != "" && req.Status == "ready" {
-				mail := order.NewStatusMail()
-				if err := s.Mailer.Send(mail); err != nil {
-					log.Println(err)
-				}
-			}
-		}()
+		if !s.can(r, "orders:admin") && req.Status != order.StatusCancelled {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", fmt.Errorf("customers can only cancel their orders"))
+			return
+		}
+
+		if _, err := order.Transition(current.Status, req.Status, editor); err != nil {
+			s.respondErr(w, r, http.StatusConflict, "updating-order", err)
+			return
+		}
+
+		movements := orderMovements(current, current.Status, req.Status, editor)
+		if err := s.applyMovements(movements); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-stock", err)
+			return
+		}
+
+		var events []string
+		if req.Status == order.StatusReady {
+			events = append(events, outbox.KindOrderReady)
+		}
+
+		o, err := os.UpdateStatus(id, req.Status, editor, version, events...)
+		if err != nil {
+			s.revertMovements(movements)
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-order", err)
+			return
+		}
+
+		if o.Status == order.StatusDelivered {
+			s.recordUnitWeights(o)
+		}
+		if o.Status == order.StatusCancelled {
+			s.shiftSlot(o.RecoveryAt, -1, -o.Grams())
+		}
 
 		resp := response{
-			Data: formator.NewJSONData("orders", id, req),
+			Data: formator.NewJSONData("orders", id, api_apbp.MapOrderToJSON(o)),
 		}
+		s.setETag(w, o.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -286,7 +517,7 @@ func (s *Server) updateOrderRecovery() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := s.getParam(r, "id")
-		os := s.Store.Order()
+		os := s.store(r).Order()
 		req := request{}
 
 		err := s.decode(w, r, &req)
@@ -305,11 +536,44 @@ func (s *Server) updateOrderRecovery() http.HandlerFunc {
 			return
 		}
 
-		_, err = os.UpdateField(id, "recovery_at", req.Recovery)
+		current, err := s.authorizeOrder(r, id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", err)
+			return
+		}
+
+		version, err := s.expectVersion(r, current.Version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		if err := order.Editable(current.Status, s.can(r, "orders:admin")); err != nil {
+			s.respondErr(w, r, http.StatusConflict, "updating-order", err)
+			return
+		}
+
+		// an order moved within its slot keeps its booking, otherwise the new slot is booked
+		// before the old one is released
+		moved := !s.sameSlot(current.RecoveryAt, req.Recovery)
+		if moved {
+			if err := s.bookSlot(req.Recovery, current.Grams(), current.ID); err != nil {
+				s.respondErr(w, r, slotStatus(err), "booking-slot", err)
+				return
+			}
+		}
+
+		o, err := os.UpdateField(id, "recovery_at", req.Recovery, version)
 		if err != nil {
+			if moved {
+				s.shiftSlot(req.Recovery, -1, -current.Grams())
+			}
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-order", err)
 			return
 		}
+		if moved {
+			s.shiftSlot(current.RecoveryAt, -1, -current.Grams())
+		}
 
 		// order := api_apbp.MapOrderToJSON(o)
 
@@ -325,6 +589,62 @@ func (s *Server) updateOrderRecovery() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("orders", id, req),
 		}
+		s.setETag(w, o.Version)
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) updateLineWeight() http.HandlerFunc {
+	type request struct {
+		ActualWeight float32 `json:"actual_weight,omitempty" validate:"required,gt=0"`
+	}
+
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+		req := request{}
+
+		index, err := strconv.Atoi(s.getParam(r, "index"))
+		if err != nil || index < 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "parsing-line-index", fmt.Errorf("line index must be a positive integer"))
+			return
+		}
+
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-json", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "order-json-validation", err)
+			return
+		}
+
+		version, err := s.ifMatch(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		o, err := s.store(r).Order().UpdateLineWeight(id, index, req.ActualWeight, version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-order", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("orders", id, api_apbp.MapOrderToJSON(o)),
+		}
+		s.setETag(w, o.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -334,18 +654,7 @@ func (s *Server) updateOrderProducts() http.HandlerFunc {
 		Quantity  float32            `json:"quantity,omitempty" validate:"required"`
 		Unit      string             `json:"unit,omitempty" validate:"required,oneof=gr p"`
 		ProductID primitive.ObjectID `json:"product_id,omitempty" validate:"required"`
-	}
-
-	type productLine struct {
-		Quantity float32 `json:"quantity,omitempty"`
-		Unit     string  `json:"unit,omitempty"`
-		Ref      string  `json:"ref,omitempty"`
-		Name     string  `json:"name,omitempty"`
-		AUW      float32 `json:"auw,omitempty"`
-	}
-
-	type upd struct {
-		Products []productLine
+		Option    string             `json:"option,omitempty"`
 	}
 
 	type request struct {
@@ -358,7 +667,7 @@ func (s *Server) updateOrderProducts() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
-		os := s.Store.Order()
+		os := s.store(r).Order()
 		req := request{}
 
 		err := s.decode(w, r, &req)
@@ -377,35 +686,80 @@ func (s *Server) updateOrderProducts() http.HandlerFunc {
 			return
 		}
 
-		productLines := make([]productLine, len(req.ProductsLines))
+		editor, err := s.sessionUserID(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		current, err := s.authorizeOrder(r, uid)
+		if err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-order", err)
+			return
+		}
+
+		version, err := s.expectVersion(r, current.Version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		if err := order.Editable(current.Status, s.can(r, "orders:admin")); err != nil {
+			s.respondErr(w, r, http.StatusConflict, "updating-order", err)
+			return
+		}
+
+		productLines := make([]order.ProductLine, len(req.ProductsLines))
 		for i, pl := range req.ProductsLines {
-			product, err := s.Store.Product().Read(pl.ProductID.Hex())
+			product, err := s.store(r).Product().Read(pl.ProductID.Hex())
 			if err != nil {
 				s.respondErr(w, r, http.StatusBadRequest, "product-not-found", err)
 				return
 			}
-			productLines[i] = productLine{
-				Quantity: pl.Quantity,
-				Unit:     pl.Unit,
-				Ref:      product.Ref,
-				Name:     product.Name,
-				AUW:      product.AUW,
+			productLines[i], err = orderLine(s.store(r), product, pl.Quantity, pl.Unit, pl.Option)
+			if err != nil {
+				s.respondErr(w, r, http.StatusBadRequest, "option-not-found", err)
+				return
 			}
 		}
 
-		o := upd{
-			Products: productLines,
+		if err := order.CheckPrices(productLines); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-prices", err)
+			return
+		}
+
+		// reserved stock follows the new lines of confirmed orders
+		var movements []stock.Movement
+		if current.Status == order.StatusConfirm || current.Status == order.StatusReady {
+			movements = append(
+				linesMovements(current.ID, current.ProductsLines, []string{stock.MoveRelease}, editor),
+				linesMovements(current.ID, productLines, []string{stock.MoveReserve}, editor)...,
+			)
+		}
+
+		if err := s.applyMovements(movements); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-stock", err)
+			return
+		}
+
+		upd := bson.M{
+			"products": bson.M{"$literal": productLines},
+			"totals":   bson.M{"$literal": order.ComputeTotals(productLines)},
 		}
 
-		_, err = os.UpdateField(uid, "products", o.Products)
+		// expecting the version the lines were read at keeps concurrent edits from overwriting each other
+		o, err := os.UpdateFields(uid, upd, version)
 		if err != nil {
+			s.revertMovements(movements)
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-order", err)
 			return
 		}
+		s.shiftSlot(o.RecoveryAt, 0, o.Grams()-current.Grams())
 
 		resp := response{
-			Data: formator.NewJSONData("orders", uid, req),
+			Data: formator.NewJSONData("orders", uid, api_apbp.MapOrderToJSON(o)),
 		}
+		s.setETag(w, o.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -418,11 +772,33 @@ func (s *Server) deleteOrder() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
 
-		err := s.Store.Order().Delete(uid)
+		editor, err := s.sessionUserID(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		o, err := s.store(r).Order().Read(uid, false)
 		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-order", err)
+			return
+		}
+
+		movements := orderMovements(o, o.Status, order.StatusCancelled, editor)
+		if err := s.applyMovements(movements); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-stock", err)
+			return
+		}
+
+		err = s.store(r).Order().Delete(uid)
+		if err != nil {
+			s.revertMovements(movements)
 			s.respondErr(w, r, http.StatusInternalServerError, "deleting-order", err)
 			return
 		}
+		if o.Status != order.StatusCancelled {
+			s.shiftSlot(o.RecoveryAt, -1, -o.Grams())
+		}
 
 		resp := response{
 			Data: formator.NewJSONData("orders", uid, nil),
@@ -430,3 +806,114 @@ func (s *Server) deleteOrder() http.HandlerFunc {
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
+
+func (s *Server) restoreOrder() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid := s.getParam(r, "id")
+
+		editor, err := s.sessionUserID(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		o, err := s.store(r).Order().Restore(uid)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "restoring-order", err)
+			return
+		}
+
+		// deleting released the stock reserved by orders in preparation
+		var movements []stock.Movement
+		if o.Status == order.StatusConfirm || o.Status == order.StatusReady {
+			movements = linesMovements(o.ID, o.ProductsLines, []string{stock.MoveReserve}, editor)
+		}
+
+		if err := s.applyMovements(movements); err != nil {
+			if err := s.store(r).Order().Delete(uid); err != nil {
+				log.Println(err)
+			}
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-stock", err)
+			return
+		}
+
+		// deleting released the slot booking, restored orders are counted back even over capacity
+		if o.Status != order.StatusCancelled {
+			s.shiftSlot(o.RecoveryAt, 1, o.Grams())
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("orders", uid, api_apbp.MapOrderToJSON(o)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// authorizeOrder read order and checks that the session user is an admin or the order customer
+func (s *Server) authorizeOrder(r *http.Request, id string) (order.Order, error) {
+	o, err := s.store(r).Order().Read(id, false)
+	if err != nil {
+		return o, err
+	}
+
+	return o, s.authorize(r, "orders:admin", o.RelationShip.Customer.Hex())
+}
+
+// orderLine copy the product current data into an order line, with the named preparation option if any
+func orderLine(st store.Store, p product.Product, quantity float32, unit, option string) (order.ProductLine, error) {
+	pl := order.ProductLine{
+		Quantity: quantity,
+		Unit:     unit,
+		Ref:      p.Ref,
+		Name:     p.Name,
+		AUW:      p.AUW,
+		Price:    p.Price,
+	}
+
+	for _, c := range p.Components {
+		cp, err := st.Product().ReadByRef(c.Ref)
+		if err != nil {
+			return pl, fmt.Errorf("component %v of product %v not found", c.Ref, p.Ref)
+		}
+		pl.Components = append(pl.Components, order.Component{
+			Ref:      c.Ref,
+			Name:     cp.Name,
+			Quantity: c.Quantity,
+			Unit:     c.Unit,
+			AUW:      cp.AUW,
+		})
+	}
+
+	if option == "" {
+		return pl, nil
+	}
+
+	o, ok := p.Option(option)
+	if !ok {
+		return pl, fmt.Errorf("product %v has no preparation option %v", p.Ref, option)
+	}
+	pl.Option = &o
+	return pl, nil
+}
+
+// recordUnitWeights feed weighed piece lines of a delivered order back into products average
+// unit weight, once per line with its final weight. Prepared and bundle lines are left out
+// as their weight isn't the one of raw pieces
+func (s *Server) recordUnitWeights(o order.Order) {
+	for _, pl := range o.ProductsLines {
+		if pl.Unit != "p" || pl.ActualWeight == nil || pl.Quantity <= 0 {
+			continue
+		}
+		if pl.Option != nil || len(pl.Components) > 0 {
+			continue
+		}
+
+		if _, err := s.Store.Product().RecordUnitWeight(pl.Ref, *pl.ActualWeight/pl.Quantity); err != nil {
+			log.Println(err)
+		}
+	}
+}
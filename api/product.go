@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/valensto/api_apbp"
 	"github.com/valensto/api_apbp/api/formator"
 	"github.com/valensto/api_apbp/infra/repo/product"
+	"github.com/valensto/api_apbp/infra/store"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -22,7 +24,8 @@ func (s *Server) listProduct() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		f := filter.ParseQuery(r.URL.RequestURI())
-		meta, products, err := s.Store.Product().List(f)
+		f.WithDeleted = f.WithDeleted && s.can(r, "products:write")
+		meta, products, err := s.store(r).Product().List(f)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "listing-products", err)
 			return
@@ -36,34 +39,7 @@ func (s *Server) listProduct() http.HandlerFunc {
 		resp := response{
 			Meta:  meta,
 			Data:  jsonProducts,
-			Links: f.Pagination.GetLinks(r.URL.RequestURI(), meta.TotalElements),
-		}
-
-		s.respond(w, r, http.StatusOK, resp)
-	}
-}
-
-func (s *Server) listCategory() http.HandlerFunc {
-	type response struct {
-		Data []formator.JsonData `json:"data"`
-	}
-
-	return func(w http.ResponseWriter, r *http.Request) {
-		f := filter.ParseQuery(r.URL.RequestURI())
-
-		categories, err := s.Store.Product().Categories(f)
-		if err != nil {
-			s.respondErr(w, r, http.StatusInternalServerError, "listing-categories", err)
-			return
-		}
-
-		var jsonCategories = make([]formator.JsonData, len(categories))
-		for i, c := range categories {
-			jsonCategories[i] = formator.NewJSONData("category", "", api_apbp.MapCategoryToJSON(&c))
-		}
-
-		resp := response{
-			Data: jsonCategories,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
 		}
 
 		s.respond(w, r, http.StatusOK, resp)
@@ -99,12 +75,20 @@ func (s *Server) createProduct() http.HandlerFunc {
 			ModifiedAt:  time.Now(),
 			Ref:         strings.ToUpper(req.Ref),
 			Name:        req.Name,
-			Category:    req.Category,
+			CategoryID:  req.CategoryID,
 			Description: req.Description,
 			AUW:         req.AUW,
+			Price:       priceFromJSON(req.Price),
+			Options:     optionsFromJSON(req.Options),
+			Components:  componentsFromJSON(req.Components),
 		}
 
-		err = s.Store.Product().Create(p)
+		if err := checkProduct(s.store(r), p); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-product", err)
+			return
+		}
+
+		err = s.store(r).Product().Create(p)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "creating-product", err)
 			return
@@ -119,11 +103,13 @@ func (s *Server) createProduct() http.HandlerFunc {
 
 func (s *Server) updateProduct() http.HandlerFunc {
 	type request struct {
-		Ref         string  `json:"ref" validate:"required,len=8,ref"`
-		Name        string  `json:"name" validate:"required"`
-		Category    string  `json:"category,omitempty"`
-		Description string  `json:"description,omitempty"`
-		AUW         float32 `json:"average_unit_weight" validate:"required,numeric"`
+		Ref         string                   `json:"ref" validate:"required,len=8,ref"`
+		Name        string                   `json:"name" validate:"required"`
+		CategoryID  *primitive.ObjectID      `json:"category_id,omitempty"`
+		Description string                   `json:"description,omitempty"`
+		Price       *api_apbp.JsonPrice      `json:"price,omitempty"`
+		Options     []api_apbp.JsonOption    `json:"options,omitempty" validate:"omitempty,unique=Name,dive"`
+		Components  []api_apbp.JsonComponent `json:"components,omitempty" validate:"omitempty,unique=Ref,dive"`
 	}
 
 	type response struct {
@@ -134,7 +120,7 @@ func (s *Server) updateProduct() http.HandlerFunc {
 		uid := s.getParam(r, "id")
 		req := request{}
 
-		ps := s.Store.Product()
+		ps := s.store(r).Product()
 
 		err := s.decode(w, r, &req)
 		if err != nil {
@@ -155,12 +141,25 @@ func (s *Server) updateProduct() http.HandlerFunc {
 		p := product.Product{
 			Ref:         strings.ToUpper(req.Ref),
 			Name:        req.Name,
-			Category:    req.Category,
+			CategoryID:  req.CategoryID,
 			Description: req.Description,
-			AUW:         req.AUW,
+			Price:       priceFromJSON(req.Price),
+			Options:     optionsFromJSON(req.Options),
+			Components:  componentsFromJSON(req.Components),
+		}
+
+		if err := checkProduct(s.store(r), p); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-product", err)
+			return
 		}
 
-		up, err := ps.UpdateFields(uid, p)
+		version, err := s.ifMatch(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		up, err := ps.UpdateFields(uid, p, version)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-product", err)
 			return
@@ -169,6 +168,7 @@ func (s *Server) updateProduct() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("products", up.ID.Hex(), api_apbp.MapProductToJSON(&up)),
 		}
+		s.setETag(w, up.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -182,7 +182,7 @@ func (s *Server) getProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
 
-		p, err := s.Store.Product().Read(uid)
+		p, err := s.store(r).Product().Read(uid)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "reading-product", err)
 			return
@@ -191,6 +191,7 @@ func (s *Server) getProduct() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("products", p.ID.Hex(), api_apbp.MapProductToJSON(&p)),
 		}
+		s.setETag(w, p.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -203,7 +204,7 @@ func (s *Server) deleteProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
 
-		err := s.Store.Product().Delete(uid)
+		err := s.store(r).Product().Delete(uid)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "deleting-product", err)
 			return
@@ -215,3 +216,94 @@ func (s *Server) deleteProduct() http.HandlerFunc {
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
+
+func (s *Server) restoreProduct() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid := s.getParam(r, "id")
+
+		p, err := s.store(r).Product().Restore(uid)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "restoring-product", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("products", p.ID.Hex(), api_apbp.MapProductToJSON(&p)),
+		}
+		s.setETag(w, p.Version)
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func priceFromJSON(p *api_apbp.JsonPrice) *product.Price {
+	if p == nil {
+		return nil
+	}
+	return &product.Price{
+		PerKg:    p.PerKg,
+		PerPiece: p.PerPiece,
+		Currency: strings.ToUpper(p.Currency),
+		VATRate:  p.VATRate,
+	}
+}
+
+func optionsFromJSON(os []api_apbp.JsonOption) []product.Option {
+	if len(os) == 0 {
+		return nil
+	}
+
+	options := make([]product.Option, len(os))
+	for i, o := range os {
+		options[i] = product.Option{
+			Name:        o.Name,
+			Yield:       o.Yield,
+			PrepMinutes: o.PrepMinutes,
+		}
+	}
+	return options
+}
+
+func componentsFromJSON(cs []api_apbp.JsonComponent) []product.Component {
+	if len(cs) == 0 {
+		return nil
+	}
+
+	components := make([]product.Component, len(cs))
+	for i, c := range cs {
+		components[i] = product.Component{
+			Ref:      strings.ToUpper(c.Ref),
+			Quantity: c.Quantity,
+			Unit:     c.Unit,
+		}
+	}
+	return components
+}
+
+// checkProduct ensure the product category exists and bundle components are existing products
+// which are not bundles themselves
+func checkProduct(st store.Store, p product.Product) error {
+	if p.CategoryID != nil {
+		if _, err := st.Category().Read(p.CategoryID.Hex()); err != nil {
+			return fmt.Errorf("category %v not found", p.CategoryID.Hex())
+		}
+	}
+
+	for _, c := range p.Components {
+		if c.Ref == p.Ref {
+			return fmt.Errorf("product %v can't be one of its own components", p.Ref)
+		}
+
+		cp, err := st.Product().ReadByRef(c.Ref)
+		if err != nil {
+			return err
+		}
+		if cp.IsBundle() {
+			return fmt.Errorf("component %v is a bundle, bundles can't be nested", c.Ref)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/infra/repo/purchase"
+	"github.com/valensto/api_apbp/infra/repo/stock"
+	"github.com/valensto/api_apbp/infra/repo/supplier"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Server) listPurchase() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		meta, purchases, err := s.store(r).Purchase().List(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-purchases", err)
+			return
+		}
+
+		var jsonPurchases = make([]formator.JsonData, len(purchases))
+		for i, p := range purchases {
+			jsonPurchases[i] = formator.NewJSONData("purchases", p.ID.Hex(), api_apbp.MapPurchaseToJSON(p))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonPurchases,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) getPurchase() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		p, err := s.store(r).Purchase().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-purchase", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("purchases", p.ID.Hex(), api_apbp.MapPurchaseToJSON(p)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// generatePurchases draft a purchase per supplier covering what confirmed and ready orders of the range
+// need minus stock on hand, drafts already generated for the same supplier and range are replaced
+func (s *Server) generatePurchases() http.HandlerFunc {
+	type request struct {
+		Start time.Time `json:"start" validate:"required"`
+		End   time.Time `json:"end" validate:"required,gtfield=Start"`
+	}
+
+	type response struct {
+		Meta map[string][]string `json:"meta"`
+		Data []formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := request{}
+
+		editor, err := s.sessionUserID(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "decoding-editor", err)
+			return
+		}
+
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-json", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "purchase-json-validation", err)
+			return
+		}
+
+		f := filter.Query{Range: &filter.Range{Start: req.Start, End: req.End}}
+		fs, err := s.store(r).Order().ForecastCommitted(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-forecast", err)
+			return
+		}
+
+		// forecast covers orders which already reserved stock, so what is on hand is deducted as a whole
+		var needs []purchase.Line
+		for _, fo := range fs {
+			st, err := s.store(r).Stock().Read(fo.Product.Ref)
+			if err != nil {
+				s.respondErr(w, r, http.StatusInternalServerError, "reading-stock", err)
+				return
+			}
+
+			if need := float64(fo.Quantity) - st.Quantity; need > 0 {
+				needs = append(needs, purchase.Line{
+					Ref:      fo.Product.Ref,
+					Name:     fo.Product.Name,
+					Quantity: math.Ceil(need),
+				})
+			}
+		}
+
+		refs := make([]string, len(needs))
+		for i, l := range needs {
+			refs[i] = l.Ref
+		}
+
+		var suppliers []supplier.Supplier
+		if len(refs) > 0 {
+			suppliers, err = s.store(r).Supplier().Supplying(refs)
+			if err != nil {
+				s.respondErr(w, r, http.StatusInternalServerError, "listing-suppliers", err)
+				return
+			}
+		}
+
+		now := time.Now()
+		purchases := make(map[primitive.ObjectID]*purchase.Purchase)
+		var ids []primitive.ObjectID
+		unassigned := []string{}
+
+		for _, l := range needs {
+			sp, ok := supplying(suppliers, l.Ref)
+			if !ok {
+				unassigned = append(unassigned, l.Ref)
+				continue
+			}
+
+			p, ok := purchases[sp.ID]
+			if !ok {
+				p = &purchase.Purchase{
+					ID:         primitive.NewObjectID(),
+					CreatedAt:  now,
+					ModifiedAt: now,
+					Supplier:   sp.ID,
+					Editor:     editor,
+					Status:     purchase.StatusDraft,
+					From:       req.Start,
+					To:         req.End,
+					ExpectedAt: now.AddDate(0, 0, sp.LeadTime),
+				}
+				purchases[sp.ID] = p
+				ids = append(ids, sp.ID)
+			}
+			p.Lines = append(p.Lines, l)
+		}
+
+		data := make([]formator.JsonData, len(ids))
+		for i, id := range ids {
+			p, err := s.store(r).Purchase().SaveDraft(*purchases[id])
+			if err != nil {
+				s.respondErr(w, r, http.StatusInternalServerError, "creating-purchase", err)
+				return
+			}
+			data[i] = formator.NewJSONData("purchases", p.ID.Hex(), api_apbp.MapPurchaseToJSON(p))
+		}
+
+		resp := response{
+			Meta: map[string][]string{"unassigned": unassigned},
+			Data: data,
+		}
+		s.respond(w, r, http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) sendPurchase() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		p, err := s.store(r).Purchase().Send(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "sending-purchase", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("purchases", p.ID.Hex(), api_apbp.MapPurchaseToJSON(p)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// receivePurchase record received weights and put them in stock
+func (s *Server) receivePurchase() http.HandlerFunc {
+	type reqLine struct {
+		Ref      string  `json:"ref" validate:"required"`
+		Received float64 `json:"received" validate:"min=0"`
+	}
+
+	type request struct {
+		Lines []reqLine `json:"lines" validate:"required,min=1,dive,required"`
+	}
+
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+		req := request{}
+
+		editor, err := s.sessionUserID(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "decoding-editor", err)
+			return
+		}
+
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-json", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "purchase-json-validation", err)
+			return
+		}
+
+		current, err := s.store(r).Purchase().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-purchase", err)
+			return
+		}
+
+		lines := make([]purchase.Line, len(current.Lines))
+		copy(lines, current.Lines)
+
+		var movements []stock.Movement
+		now := time.Now()
+		for _, rl := range req.Lines {
+			i := lineIndex(lines, rl.Ref)
+			if i < 0 {
+				s.respondErr(w, r, http.StatusBadRequest, "receiving-purchase", fmt.Errorf("%v is not part of purchase %v", rl.Ref, id))
+				return
+			}
+
+			received := rl.Received
+			lines[i].Received = &received
+
+			if received > 0 {
+				movements = append(movements, stock.Movement{
+					ID:        primitive.NewObjectID(),
+					CreatedAt: now,
+					Ref:       rl.Ref,
+					Kind:      stock.MoveIn,
+					Quantity:  received,
+					Editor:    editor,
+					Note:      fmt.Sprintf("purchase %v", id),
+				})
+			}
+		}
+
+		if err := s.applyMovements(movements); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-stock", err)
+			return
+		}
+
+		p, err := s.store(r).Purchase().Receive(id, lines)
+		if err != nil {
+			s.revertMovements(movements)
+			s.respondErr(w, r, http.StatusInternalServerError, "receiving-purchase", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("purchases", p.ID.Hex(), api_apbp.MapPurchaseToJSON(p)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) deletePurchase() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		err := s.store(r).Purchase().Delete(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "deleting-purchase", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("purchases", id, nil),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// supplying return the first supplier of product ref
+func supplying(suppliers []supplier.Supplier, ref string) (supplier.Supplier, bool) {
+	for _, sp := range suppliers {
+		for _, r := range sp.Refs {
+			if r == ref {
+				return sp, true
+			}
+		}
+	}
+	return supplier.Supplier{}, false
+}
+
+func lineIndex(lines []purchase.Line, ref string) int {
+	for i, l := range lines {
+		if l.Ref == ref {
+			return i
+		}
+	}
+	return -1
+}
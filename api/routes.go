@@ -10,62 +10,140 @@ func (s *Server) routes() {
 	s.Router.Route("/v1", func(r chi.Router) {
 
 		r.Route("/users", func(r chi.Router) {
-			r.Get("/", s.restricted(s.listUser(false)))
-			r.Get("/search", s.restricted(s.searchUser()))
-			r.Get("/admin", s.restricted(s.listUser(true)))
+			r.Get("/", s.require("users:admin", s.listUser(false)))
+			r.Get("/search", s.require("users:admin", s.searchUser()))
+			r.Get("/admin", s.require("users:admin", s.listUser(true)))
 
-			r.Post("/", s.restricted(s.createUser()))
+			r.Post("/", s.require("users:admin", s.createUser()))
 
 			r.Route("/{id}", func(r chi.Router) {
-				r.Put("/", s.restricted(s.updateUser()))
-				r.Put("/password", s.restricted(s.updatePwd()))
-				r.Put("/address", s.restricted(s.updateAddress()))
-				r.Put("/role", s.restricted(s.updateRole()))
+				r.Put("/", s.require("users:write", s.updateUser()))
+				r.Put("/password", s.require("users:write", s.updatePwd()))
+				r.Put("/address", s.require("users:write", s.updateAddress()))
+				r.Put("/role", s.require("users:admin", s.updateRole()))
 
-				r.Get("/", s.restricted(s.getUser()))
-				r.Delete("/", s.restricted(s.deleteUser()))
+				r.Get("/", s.require("users:read", s.getUser()))
+				r.Delete("/", s.require("users:admin", s.deleteUser()))
+				r.Post("/restore", s.require("users:admin", s.restoreUser()))
 
-				r.Post("/password", s.restricted(s.updatePwd()))
+				r.Post("/password", s.require("users:write", s.updatePwd()))
 			})
 		})
 
 		r.Route("/products", func(r chi.Router) {
-			r.Get("/", s.restricted(s.listProduct()))
-			r.Get("/search", s.restricted(s.listProduct()))
+			r.Get("/", s.require("products:read", s.listProduct()))
+			r.Get("/search", s.require("products:read", s.listProduct()))
 
-			r.Post("/", s.restricted(s.createProduct()))
+			r.Post("/", s.require("products:write", s.createProduct()))
 
 			r.Route("/{id}", func(r chi.Router) {
-				r.Put("/", s.restricted(s.updateProduct()))
-				r.Get("/", s.restricted(s.getProduct()))
-				r.Delete("/", s.restricted(s.deleteProduct()))
+				r.Put("/", s.require("products:write", s.updateProduct()))
+				r.Get("/", s.require("products:read", s.getProduct()))
+				r.Delete("/", s.require("products:write", s.deleteProduct()))
+				r.Post("/restore", s.require("products:write", s.restoreProduct()))
+
+				r.Get("/stock", s.require("stock:read", s.getStock()))
+				r.Get("/stock/movements", s.require("stock:read", s.listMovement()))
+				r.Post("/stock/movements", s.require("stock:write", s.createMovement()))
 			})
 		})
 
 		r.Route("/categories", func(r chi.Router) {
-			r.Get("/", s.restricted(s.listCategory()))
+			r.Get("/", s.require("products:read", s.listCategory()))
+			r.Get("/search", s.require("products:read", s.listCategory()))
+			r.Get("/catalog", s.require("products:read", s.listCatalog()))
+
+			r.Post("/", s.require("products:write", s.createCategory()))
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Put("/", s.require("products:write", s.updateCategory()))
+				r.Get("/", s.require("products:read", s.getCategory()))
+				r.Delete("/", s.require("products:write", s.deleteCategory()))
+			})
 		})
 
 		r.Route("/orders", func(r chi.Router) {
-			r.Get("/", s.restricted(s.listOrder()))
-			r.Get("/search", s.restricted(s.listOrder()))
-			r.Get("/forecast/confirm", s.restricted(s.forecast(true)))
-			r.Get("/forecast", s.restricted(s.forecast(false)))
+			r.Get("/", s.require("orders:read", s.listOrder()))
+			r.Get("/search", s.require("orders:read", s.listOrder()))
+			r.Get("/forecast/confirm", s.require("orders:admin", s.forecast(true)))
+			r.Get("/forecast", s.require("orders:admin", s.forecast(false)))
+			r.Get("/by-ref/{ref}", s.require("orders:read", s.getOrderByRef()))
+			r.Get("/preparation-sheet", s.require("orders:admin", s.preparationSheet()))
+
+			r.Post("/", s.require("orders:write", s.idempotent(s.createOrder())))
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Put("/status", s.require("orders:write", s.updateOrderStatus()))
+				r.Put("/products", s.require("orders:write", s.updateOrderProducts()))
+				r.Put("/recovery", s.require("orders:write", s.updateOrderRecovery()))
+				r.Put("/lines/{index}/weight", s.require("orders:admin", s.updateLineWeight()))
+				r.Get("/", s.require("orders:read", s.getOrder()))
+				r.Delete("/", s.require("orders:admin", s.deleteOrder()))
+				r.Post("/restore", s.require("orders:admin", s.restoreOrder()))
+			})
+		})
+
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.Get("/", s.require("subscriptions:read", s.listSubscriptions()))
+			r.Post("/", s.require("subscriptions:write", s.createSubscription()))
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Get("/", s.require("subscriptions:read", s.getSubscription()))
+				r.Put("/", s.require("subscriptions:write", s.updateSubscription()))
+				r.Delete("/", s.require("subscriptions:write", s.deleteSubscription()))
+			})
+		})
+
+		r.Route("/suppliers", func(r chi.Router) {
+			r.Get("/", s.require("suppliers:read", s.listSupplier()))
+			r.Get("/search", s.require("suppliers:read", s.listSupplier()))
 
-			r.Post("/", s.restricted(s.createOrder()))
+			r.Post("/", s.require("suppliers:write", s.createSupplier()))
 
 			r.Route("/{id}", func(r chi.Router) {
-				r.Put("/status", s.restricted(s.updateOrderStatus()))
-				r.Put("/products", s.restricted(s.updateOrderProducts()))
-				r.Put("/recovery", s.restricted(s.updateOrderRecovery()))
-				r.Get("/", s.restricted(s.getOrder()))
-				r.Delete("/", s.restricted(s.deleteOrder()))
+				r.Put("/", s.require("suppliers:write", s.updateSupplier()))
+				r.Get("/", s.require("suppliers:read", s.getSupplier()))
+				r.Delete("/", s.require("suppliers:write", s.deleteSupplier()))
 			})
 		})
 
+		r.Route("/purchases", func(r chi.Router) {
+			r.Get("/", s.require("purchases:read", s.listPurchase()))
+			r.Get("/search", s.require("purchases:read", s.listPurchase()))
+
+			r.Post("/generate", s.require("purchases:write", s.generatePurchases()))
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Put("/send", s.require("purchases:write", s.sendPurchase()))
+				r.Put("/receive", s.require("purchases:write", s.receivePurchase()))
+				r.Get("/", s.require("purchases:read", s.getPurchase()))
+				r.Delete("/", s.require("purchases:write", s.deletePurchase()))
+			})
+		})
+
+		r.Route("/search", func(r chi.Router) {
+			r.Get("/", s.require("search:read", s.search()))
+		})
+
+		r.Route("/audit", func(r chi.Router) {
+			r.Get("/", s.require("audit:read", s.listAudit()))
+		})
+
+		r.Route("/notifications", func(r chi.Router) {
+			r.Get("/", s.require("notifications:read", s.listNotifications()))
+		})
+
+		r.Route("/slots", func(r chi.Router) {
+			r.Get("/", s.require("orders:read", s.listSlots()))
+		})
+
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/login", s.login())
-			r.Post("/logout", s.login())
+			r.Post("/refresh", s.refresh())
+			r.Post("/logout", s.restricted(s.logout()))
+
+			r.Post("/password/forgot", s.forgotPwd())
+			r.Post("/password/reset", s.resetPwd())
 		})
 
 	})
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// search return users, products and orders matching the term, most relevant first
+func (s *Server) search() http.HandlerFunc {
+	type hit struct {
+		score float64
+		data  formator.JsonData
+	}
+
+	type response struct {
+		Meta map[string]interface{} `json:"meta"`
+		Data []formator.JsonData    `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		if f.Term == "" {
+			s.respondErr(w, r, http.StatusBadRequest, "parsing-params", fmt.Errorf("term param is required, ?term=bar"))
+			return
+		}
+
+		q := filter.Query{
+			Term:       f.Term,
+			Pagination: pagination.Query{Limit: f.Pagination.Limit},
+		}
+
+		var hits []hit
+
+		_, users, err := s.store(r).User().List(q, false)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "searching-users", err)
+			return
+		}
+		for _, u := range users {
+			hits = append(hits, hit{u.Score, formator.NewJSONData("users", u.ID.Hex(), api_apbp.MapUserToJSON(u))})
+		}
+
+		_, products, err := s.store(r).Product().List(q)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "searching-products", err)
+			return
+		}
+		for _, p := range products {
+			hits = append(hits, hit{p.Score, formator.NewJSONData("products", p.ID.Hex(), api_apbp.MapProductToJSON(&p))})
+		}
+
+		_, orders, err := s.store(r).Order().List(q, primitive.NilObjectID)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "searching-orders", err)
+			return
+		}
+		for _, o := range orders {
+			hits = append(hits, hit{o.Score, formator.NewJSONData("orders", o.ID.Hex(), api_apbp.MapOrderToJSON(o))})
+		}
+
+		sort.SliceStable(hits, func(i, j int) bool {
+			return hits[i].score > hits[j].score
+		})
+		if len(hits) > q.Pagination.Limit {
+			hits = hits[:q.Pagination.Limit]
+		}
+
+		data := make([]formator.JsonData, len(hits))
+		for i, h := range hits {
+			data[i] = h.data
+		}
+
+		resp := response{
+			Meta: map[string]interface{}{
+				"term":  f.Term,
+				"total": len(data),
+			},
+			Data: data,
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
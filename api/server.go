@@ -3,19 +3,28 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 
 	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/api/session"
 	config "github.com/valensto/api_apbp/configs"
 	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/audit"
 	"github.com/valensto/api_apbp/infra/store"
+	"github.com/valensto/api_apbp/pkg/etag"
 	"github.com/valensto/api_apbp/pkg/mailer"
+	"github.com/valensto/api_apbp/pkg/slot"
 	validator "github.com/valensto/api_apbp/pkg/validator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var errForbidden = errors.New("you are not allowed to access this resource")
+
 // Server is a struct representation of a app server
 type Server struct {
 	Router    *chi.Mux
@@ -23,14 +32,21 @@ type Server struct {
 	Validator *validator.Valider
 	Conf      config.App
 	Mailer    mailer.Sender
+	Slots     slot.Schedule
 }
 
 // NewServer is a struct of app server
 func NewServer(conf config.App) (*Server, error) {
+	slots, err := slot.NewSchedule(conf.Pickup.Hours, conf.Pickup.SlotLength, conf.Pickup.MaxOrders, conf.Pickup.MaxWeight)
+	if err != nil {
+		return nil, fmt.Errorf("error during pickup slots configuration. got=%w", err)
+	}
+
 	s := &Server{
 		Router:    chi.NewRouter(),
 		Validator: validator.NewValider(),
 		Conf:      conf,
+		Slots:     slots,
 	}
 
 	s.routes()
@@ -43,6 +59,66 @@ func (s *Server) InitStructValidator() error {
 	return s.Validator.RegisterValidator()
 }
 
+// sessionUserID return the authenticated user id
+func (s *Server) sessionUserID(r *http.Request) (primitive.ObjectID, error) {
+	uIDstr, err := session.GetUserID(r.Context())
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return primitive.ObjectIDFromHex(uIDstr)
+}
+
+// store return the store bound to the request context, mutations are audited as its user
+func (s *Server) store(r *http.Request) store.Store {
+	uid, _ := session.GetUserID(r.Context())
+	ctx := audit.WithActor(r.Context(), audit.Actor{
+		User:      uid,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+	return s.Store.WithContext(ctx)
+}
+
+// ifMatch return the version expected by the If-Match header, repo.AnyVersion when absent
+func (s *Server) ifMatch(r *http.Request) (int, error) {
+	h := r.Header.Get("If-Match")
+	if h == "" || h == "*" {
+		return repo.AnyVersion, nil
+	}
+
+	v, err := etag.Parse(h)
+	if err != nil {
+		return v, repo.ErrRepoOp{
+			Op:   "parsing-if-match",
+			Code: http.StatusPreconditionFailed,
+			Err:  err,
+		}
+	}
+	return v, nil
+}
+
+// expectVersion check If-Match against the version of a document read for update
+// and return the version the update must expect
+func (s *Server) expectVersion(r *http.Request, current int) (int, error) {
+	v, err := s.ifMatch(r)
+	if err != nil {
+		return v, err
+	}
+
+	if v != repo.AnyVersion && v != current {
+		return v, repo.ErrRepoOp{
+			Op:   "checking-version",
+			Code: http.StatusPreconditionFailed,
+			Err:  fmt.Errorf("%w. expected=%d got=%d", repo.ErrVersion, v, current),
+		}
+	}
+	return current, nil
+}
+
+// setETag emit the document version, it must be called before respond
+func (s *Server) setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag.Format(version))
+}
+
 func (s *Server) getParam(r *http.Request, k string) string {
 	return chi.URLParam(r, k)
 }
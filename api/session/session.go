@@ -7,7 +7,27 @@ import (
 
 type key int
 
-var idKey key = 0
+var (
+	idKey     key = 0
+	claimsKey key = 1
+)
+
+// Claims is the representation of the authenticated user rights
+type Claims struct {
+	JTI         string
+	Role        string
+	Permissions []string
+}
+
+// Can reports whether claims grant the permission
+func (c Claims) Can(perm string) bool {
+	for _, p := range c.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
 
 func WithUserID(ctx context.Context, id string) context.Context {
 	return context.WithValue(ctx, idKey, id)
@@ -20,3 +40,15 @@ func GetUserID(ctx context.Context) (string, error) {
 	}
 	return userID, nil
 }
+
+func WithClaims(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, c)
+}
+
+func GetClaims(ctx context.Context) (Claims, error) {
+	claims, ok := ctx.Value(claimsKey).(Claims)
+	if !ok {
+		return claims, fmt.Errorf("no claims find")
+	}
+	return claims, nil
+}
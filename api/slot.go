@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/booking"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/slot"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Server) listSlots() http.HandlerFunc {
+	type response struct {
+		Meta map[string]time.Time `json:"meta"`
+		Data []api_apbp.JsonSlot  `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+
+		if f.Range.End.IsZero() || !f.Range.End.After(f.Range.Start) {
+			s.respondErr(w, r, http.StatusBadRequest, "parsing-params", fmt.Errorf("end param is a required param after start, ?end=2006-01-02T15:04:05.000Z"))
+			return
+		}
+
+		slots, err := s.bookedSlots(*f.Range, primitive.NilObjectID)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-slots", err)
+			return
+		}
+
+		jsonSlots := make([]api_apbp.JsonSlot, len(slots))
+		for i, sl := range slots {
+			jsonSlots[i] = api_apbp.MapSlotToJSON(sl, s.Slots)
+		}
+
+		resp := response{
+			Meta: map[string]time.Time{
+				"from": f.Range.Start,
+				"to":   f.Range.End,
+			},
+			Data: jsonSlots,
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// bookedSlots return the slots of the range filled with the orders to pick up in it, exclude order is left out
+func (s *Server) bookedSlots(rg filter.Range, exclude primitive.ObjectID) ([]slot.Slot, error) {
+	os, err := s.Store.Order().Pickups(rg, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := make([]slot.Booking, len(os))
+	for i, o := range os {
+		bookings[i] = slot.Booking{At: o.RecoveryAt, Weight: o.Grams()}
+	}
+
+	// opening hours are expressed in the shop local time
+	return slot.Fill(s.Slots.Slots(rg.Start.Local(), rg.End.Local()), bookings), nil
+}
+
+// bookSlot count an order of weight grams in the slot of the given time if it fits. The first
+// booking of a slot seeds its count from the orders already picked up in it, exclude order left out
+func (s *Server) bookSlot(at time.Time, weight float64, exclude primitive.ObjectID) error {
+	sl, err := s.Slots.Find(at.Local())
+	if err != nil {
+		return err
+	}
+
+	bs := s.Store.Booking()
+	err = bs.Book(sl.Start, weight, s.Slots.MaxOrders, s.Slots.MaxWeight)
+	if !errors.Is(err, booking.ErrUncounted) {
+		return err
+	}
+
+	slots, err := s.bookedSlots(filter.Range{Start: sl.Start, End: sl.End}, exclude)
+	if err != nil {
+		return err
+	}
+	if len(slots) > 0 {
+		sl = slots[0]
+	}
+	if err := s.Slots.Check(sl, weight); err != nil {
+		return err
+	}
+
+	err = bs.Seed(booking.Booking{
+		Start:     sl.Start,
+		Orders:    sl.Orders + 1,
+		Weight:    sl.Weight + weight,
+		ExpiresAt: sl.End,
+	})
+	if errors.Is(err, repo.ErrDuplicate) {
+		// another booking counted the slot first
+		return bs.Book(sl.Start, weight, s.Slots.MaxOrders, s.Slots.MaxWeight)
+	}
+	return err
+}
+
+// shiftSlot move the count of the slot of the given time by orders and weight grams,
+// the order change it follows is already stored so a failure is logged rather than returned
+func (s *Server) shiftSlot(at time.Time, orders int, weight float64) {
+	sl, err := s.Slots.Find(at.Local())
+	if err != nil {
+		return
+	}
+
+	if err := s.Store.Booking().Shift(sl.Start, orders, weight); err != nil {
+		log.Println(err)
+	}
+}
+
+// sameSlot reports whether both times fall in the same open slot
+func (s *Server) sameSlot(a, b time.Time) bool {
+	sa, err := s.Slots.Find(a.Local())
+	if err != nil {
+		return false
+	}
+	sb, err := s.Slots.Find(b.Local())
+	if err != nil {
+		return false
+	}
+	return sa.Start.Equal(sb.Start)
+}
+
+// slotStatus return the http status matching a slot booking error
+func slotStatus(err error) int {
+	switch {
+	case errors.Is(err, slot.ErrClosed):
+		return http.StatusBadRequest
+	case errors.Is(err, slot.ErrFull):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/infra/repo/stock"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Server) getStock() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		p, err := s.store(r).Product().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-product", err)
+			return
+		}
+
+		st, err := s.store(r).Stock().Read(p.Ref)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-stock", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("stocks", p.ID.Hex(), api_apbp.MapStockToJSON(st)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) listMovement() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+		f := filter.ParseQuery(r.URL.RequestURI())
+
+		p, err := s.store(r).Product().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-product", err)
+			return
+		}
+
+		meta, movements, err := s.store(r).Stock().Movements(p.Ref, f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-movements", err)
+			return
+		}
+
+		var jsonMovements = make([]formator.JsonData, len(movements))
+		for i, m := range movements {
+			jsonMovements[i] = formator.NewJSONData("movements", m.ID.Hex(), api_apbp.MapMovementToJSON(m))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonMovements,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) createMovement() http.HandlerFunc {
+	type request struct {
+		Kind     string  `json:"kind" validate:"required,oneof=in out adjust"`
+		Quantity float64 `json:"quantity" validate:"required"`
+		Note     string  `json:"note,omitempty"`
+	}
+
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		editor, err := s.sessionUserID(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		req := request{}
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-movement", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "movement-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "movement-json-validation", err)
+			return
+		}
+
+		if req.Kind != stock.MoveAdjust && req.Quantity < 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "movement-json-validation", fmt.Errorf("quantity must be positive for %v movements", req.Kind))
+			return
+		}
+
+		p, err := s.store(r).Product().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-product", err)
+			return
+		}
+
+		st, err := s.store(r).Stock().Apply(stock.Movement{
+			ID:        primitive.NewObjectID(),
+			CreatedAt: time.Now(),
+			Ref:       p.Ref,
+			Kind:      req.Kind,
+			Quantity:  req.Quantity,
+			Editor:    editor,
+			Note:      req.Note,
+		})
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "applying-movement", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("stocks", p.ID.Hex(), api_apbp.MapStockToJSON(st)),
+		}
+		s.respond(w, r, http.StatusCreated, resp)
+	}
+}
+
+// orderMovements return stock movements needed by an order moving from a status to another
+func orderMovements(o order.Order, from, to string, editor primitive.ObjectID) []stock.Movement {
+	var kinds []string
+
+	switch {
+	case to == order.StatusConfirm:
+		kinds = []string{stock.MoveReserve}
+	case to == order.StatusCancelled && (from == order.StatusConfirm || from == order.StatusReady):
+		kinds = []string{stock.MoveRelease}
+	case to == order.StatusDelivered:
+		kinds = []string{stock.MoveRelease, stock.MoveOut}
+	}
+
+	return linesMovements(o.ID, o.ProductsLines, kinds, editor)
+}
+
+func linesMovements(id primitive.ObjectID, lines []order.ProductLine, kinds []string, editor primitive.ObjectID) []stock.Movement {
+	var ms []stock.Movement
+	now := time.Now()
+
+	for _, kind := range kinds {
+		for _, pl := range expandLines(lines) {
+			oid := id
+			// reservations hold the requested quantity, what leaves the shop is the weighed one,
+			// both as the raw weight stock is counted in
+			qty := pl.Raw(pl.Grams())
+			// weighed prepared pieces are lighter than the whole pieces taken from stock
+			if kind == stock.MoveOut && (pl.Unit == "gr" || pl.Option == nil) {
+				qty = pl.Raw(pl.Weight())
+			}
+			ms = append(ms, stock.Movement{
+				ID:        primitive.NewObjectID(),
+				CreatedAt: now,
+				Ref:       pl.Ref,
+				Kind:      kind,
+				Quantity:  qty,
+				Order:     &oid,
+				Editor:    editor,
+			})
+		}
+	}
+
+	return ms
+}
+
+// expandLines replace bundle lines by their components, stock is only held for the components
+func expandLines(lines []order.ProductLine) []order.ProductLine {
+	var expanded []order.ProductLine
+	for _, pl := range lines {
+		expanded = append(expanded, pl.Expand()...)
+	}
+	return expanded
+}
+
+// applyMovements apply every movement or none, already applied ones are reverted on failure.
+// Reservations exceeding the stock on hand are logged as shortfalls
+func (s *Server) applyMovements(ms []stock.Movement) error {
+	for i, m := range ms {
+		st, err := s.Store.Stock().Apply(m)
+		if err != nil {
+			s.revertMovements(ms[:i])
+			return err
+		}
+		if m.Kind == stock.MoveReserve && st.Available() < 0 {
+			log.Printf("stock shortfall of %v: %.0f grams reserved beyond stock\n", m.Ref, -st.Available())
+		}
+	}
+	return nil
+}
+
+// revertMovements apply the opposite of movements in reverse order
+func (s *Server) revertMovements(ms []stock.Movement) {
+	opposites := map[string]string{
+		stock.MoveIn:      stock.MoveOut,
+		stock.MoveOut:     stock.MoveIn,
+		stock.MoveReserve: stock.MoveRelease,
+		stock.MoveRelease: stock.MoveReserve,
+	}
+
+	for i := len(ms) - 1; i >= 0; i-- {
+		m := ms[i]
+		m.ID = primitive.NewObjectID()
+		m.CreatedAt = time.Now()
+		if k, ok := opposites[m.Kind]; ok {
+			m.Kind = k
+		} else {
+			m.Quantity = -m.Quantity
+		}
+
+		if _, err := s.Store.Stock().Apply(m); err != nil {
+			log.Println(err)
+		}
+	}
+}
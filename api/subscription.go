@@ -0,0 +1,489 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/api/session"
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/infra/repo/outbox"
+	"github.com/valensto/api_apbp/infra/repo/subscription"
+	"github.com/valensto/api_apbp/infra/store"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"github.com/valensto/api_apbp/pkg/slot"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Server) listSubscriptions() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		f.WithDeleted = f.WithDeleted && s.can(r, "subscriptions:admin")
+
+		customer := primitive.NilObjectID
+		if !s.can(r, "subscriptions:admin") {
+			uIDstr, err := session.GetUserID(r.Context())
+			if err != nil {
+				s.respondErr(w, r, http.StatusUnauthorized, "", err)
+				return
+			}
+
+			customer, err = primitive.ObjectIDFromHex(uIDstr)
+			if err != nil {
+				s.respondErr(w, r, http.StatusBadRequest, "decoding-customer", err)
+				return
+			}
+		}
+
+		meta, subs, err := s.store(r).Subscription().List(f, customer)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-subscription", err)
+			return
+		}
+
+		var jsonSubs = make([]formator.JsonData, len(subs))
+		for i, sub := range subs {
+			jsonSubs[i] = formator.NewJSONData("subscriptions", sub.ID.Hex(), api_apbp.MapSubscriptionToJSON(sub))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonSubs,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) getSubscription() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, err := s.authorizeSubscription(r, s.getParam(r, "id"))
+		if err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-subscription", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("subscriptions", sub.ID.Hex(), api_apbp.MapSubscriptionToJSON(sub)),
+		}
+
+		s.setETag(w, sub.Version)
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) createSubscription() http.HandlerFunc {
+	type reqProductLine struct {
+		Quantity  float32            `json:"quantity,omitempty" validate:"required"`
+		Unit      string             `json:"unit,omitempty" validate:"required,oneof=gr p"`
+		ProductID primitive.ObjectID `json:"product_id,omitempty" validate:"required"`
+		Option    string             `json:"option,omitempty"`
+	}
+
+	type reqRule struct {
+		Frequency string    `json:"frequency" validate:"required,oneof=weekly biweekly"`
+		Weekday   int       `json:"weekday" validate:"min=0,max=6"`
+		At        string    `json:"at" validate:"required"`
+		Start     time.Time `json:"start,omitempty"`
+	}
+
+	type request struct {
+		Customer      primitive.ObjectID `json:"customer,omitempty" validate:"required"`
+		ProductsLines []reqProductLine   `json:"products,omitempty" validate:"required,unique,min=1,dive,required"`
+		Rule          reqRule            `json:"rule" validate:"required"`
+		Pauses        []time.Time        `json:"pauses,omitempty"`
+	}
+
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		uIDstr, err := session.GetUserID(r.Context())
+		if err != nil {
+			s.respondErr(w, r, http.StatusUnauthorized, "", err)
+			return
+		}
+
+		uid, err := primitive.ObjectIDFromHex(uIDstr)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-editor", err)
+			return
+		}
+
+		req := request{}
+		err = s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-subscription", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "subscription-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "subscription-json-validation", err)
+			return
+		}
+
+		if err := s.authorize(r, "subscriptions:admin", req.Customer.Hex()); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-subscription", err)
+			return
+		}
+
+		if _, err := s.store(r).User().Read(req.Customer.Hex()); err != nil {
+			s.respondErr(w, r, 0, "", err)
+			return
+		}
+
+		now := time.Now()
+		if req.Rule.Start.IsZero() {
+			req.Rule.Start = now
+		}
+
+		lines := make([]subscription.Line, len(req.ProductsLines))
+		for i, pl := range req.ProductsLines {
+			lines[i] = subscription.Line{Quantity: pl.Quantity, Unit: pl.Unit, ProductID: pl.ProductID, Option: pl.Option}
+		}
+
+		sub := subscription.Subscription{
+			ID:         primitive.NewObjectID(),
+			CreatedAt:  now,
+			ModifiedAt: now,
+			RelationShip: subscription.RelationShip{
+				Customer: req.Customer,
+				Editor:   uid,
+			},
+			ProductsLines: lines,
+			Rule: subscription.Rule{
+				Frequency: req.Rule.Frequency,
+				Weekday:   req.Rule.Weekday,
+				At:        req.Rule.At,
+				Start:     req.Rule.Start,
+			},
+			Pauses: req.Pauses,
+		}
+
+		if err := s.checkSubscription(r, sub); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-subscription", err)
+			return
+		}
+
+		if err := s.store(r).Subscription().Create(sub); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "creating-subscription", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("subscriptions", sub.ID.Hex(), api_apbp.MapSubscriptionToJSON(sub)),
+		}
+		s.setETag(w, sub.Version)
+		s.respond(w, r, http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) updateSubscription() http.HandlerFunc {
+	type reqProductLine struct {
+		Quantity  float32            `json:"quantity,omitempty" validate:"required"`
+		Unit      string             `json:"unit,omitempty" validate:"required,oneof=gr p"`
+		ProductID primitive.ObjectID `json:"product_id,omitempty" validate:"required"`
+		Option    string             `json:"option,omitempty"`
+	}
+
+	type reqRule struct {
+		Frequency string    `json:"frequency" validate:"required,oneof=weekly biweekly"`
+		Weekday   int       `json:"weekday" validate:"min=0,max=6"`
+		At        string    `json:"at" validate:"required"`
+		Start     time.Time `json:"start,omitempty"`
+	}
+
+	type request struct {
+		ProductsLines []reqProductLine `json:"products,omitempty" validate:"required,unique,min=1,dive,required"`
+		Rule          reqRule          `json:"rule" validate:"required"`
+		Pauses        []time.Time      `json:"pauses,omitempty"`
+	}
+
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		req := request{}
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-subscription", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "subscription-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "subscription-json-validation", err)
+			return
+		}
+
+		current, err := s.authorizeSubscription(r, id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-subscription", err)
+			return
+		}
+
+		version, err := s.expectVersion(r, current.Version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		if req.Rule.Start.IsZero() {
+			req.Rule.Start = current.Rule.Start
+		}
+
+		lines := make([]subscription.Line, len(req.ProductsLines))
+		for i, pl := range req.ProductsLines {
+			lines[i] = subscription.Line{Quantity: pl.Quantity, Unit: pl.Unit, ProductID: pl.ProductID, Option: pl.Option}
+		}
+
+		upd := subscription.Subscription{
+			ProductsLines: lines,
+			Rule: subscription.Rule{
+				Frequency: req.Rule.Frequency,
+				Weekday:   req.Rule.Weekday,
+				At:        req.Rule.At,
+				Start:     req.Rule.Start,
+			},
+			Pauses: req.Pauses,
+		}
+
+		if err := s.checkSubscription(r, upd); err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "checking-subscription", err)
+			return
+		}
+
+		sub, err := s.store(r).Subscription().UpdateFields(id, upd, version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-subscription", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("subscriptions", sub.ID.Hex(), api_apbp.MapSubscriptionToJSON(sub)),
+		}
+		s.setETag(w, sub.Version)
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) deleteSubscription() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		if _, err := s.authorizeSubscription(r, id); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-subscription", err)
+			return
+		}
+
+		if err := s.store(r).Subscription().Delete(id); err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "deleting-subscription", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("subscriptions", id, nil),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+// authorizeSubscription read the subscription if the session user may access it
+func (s *Server) authorizeSubscription(r *http.Request, id string) (subscription.Subscription, error) {
+	sub, err := s.store(r).Subscription().Read(id)
+	if err != nil {
+		return sub, err
+	}
+
+	return sub, s.authorize(r, "subscriptions:admin", sub.RelationShip.Customer.Hex())
+}
+
+// checkSubscription validate the rule, its pick up time against opening hours and the products
+func (s *Server) checkSubscription(r *http.Request, sub subscription.Subscription) error {
+	rule := sub.Rule.Recurrence()
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	// every occurrence is at the same time of the same weekday, checking one is enough
+	now := time.Now()
+	next := rule.Between(now, now.AddDate(0, 0, 15), nil)
+	if len(next) == 0 {
+		return fmt.Errorf("subscription has no pick up in the next two weeks")
+	}
+	if _, err := s.Slots.Find(next[0]); err != nil {
+		return err
+	}
+
+	lines := make([]order.ProductLine, len(sub.ProductsLines))
+	for i, l := range sub.ProductsLines {
+		p, err := s.store(r).Product().Read(l.ProductID.Hex())
+		if err != nil {
+			return fmt.Errorf("product %v not found", l.ProductID.Hex())
+		}
+		lines[i], err = orderLine(s.store(r), p, l.Quantity, l.Unit, l.Option)
+		if err != nil {
+			return err
+		}
+	}
+
+	return order.CheckPrices(lines)
+}
+
+// GenerateOrders materialize the orders of every active subscription picked up
+// between now and horizon, orders already generated are skipped
+func (s *Server) GenerateOrders(now time.Time, horizon time.Duration) {
+	subs, err := s.Store.Subscription().Active()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, sub := range subs {
+		s.generateOrders(sub, now, now.Add(horizon))
+	}
+}
+
+// generateOrders create the orders of a subscription up to until. On failure it stops
+// and records progress up to the last created order, so the next run retries from it.
+func (s *Server) generateOrders(sub subscription.Subscription, now, until time.Time) {
+	from := now
+	if sub.GeneratedUntil != nil && sub.GeneratedUntil.After(now) {
+		from = sub.GeneratedUntil.In(now.Location())
+	}
+
+	st := s.Store.WithContext(audit.WithActor(context.Background(), audit.Actor{
+		User: sub.RelationShip.Editor.Hex(),
+	}))
+
+	done := until
+	for _, at := range sub.Rule.Recurrence().Between(from, until, sub.Pauses) {
+		err := s.generateOrder(st, sub, at)
+		if errors.Is(err, repo.ErrDuplicate) {
+			continue
+		}
+		if errors.Is(err, slot.ErrFull) || errors.Is(err, slot.ErrClosed) {
+			log.Printf("skipping order of subscription %v at %v. err=%v\n", sub.ID.Hex(), at, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("cannot generate order of subscription %v at %v. err=%v\n", sub.ID.Hex(), at, err)
+			done = at.Add(-time.Second)
+			break
+		}
+	}
+
+	if done.After(from) {
+		if err := st.Subscription().MarkGenerated(sub.ID, done); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// generateOrder create a waiting order of the subscription lines at their current price,
+// the ref is only drawn once the occurrence is known to be new and its slot bookable
+func (s *Server) generateOrder(st store.Store, sub subscription.Subscription, at time.Time) error {
+	generated, err := st.Order().Generated(sub.ID, at)
+	if err != nil {
+		return err
+	}
+	if generated {
+		return repo.ErrDuplicate
+	}
+
+	customer, err := st.User().Read(sub.RelationShip.Customer.Hex())
+	if err != nil {
+		return err
+	}
+
+	lines := make([]order.ProductLine, len(sub.ProductsLines))
+	for i, l := range sub.ProductsLines {
+		p, err := st.Product().Read(l.ProductID.Hex())
+		if err != nil {
+			return err
+		}
+		lines[i], err = orderLine(st, p, l.Quantity, l.Unit, l.Option)
+		if err != nil {
+			return err
+		}
+	}
+	if err := order.CheckPrices(lines); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	totals := order.ComputeTotals(lines)
+	subID := sub.ID
+	o := order.Order{
+		ID:         primitive.NewObjectID(),
+		CreatedAt:  now,
+		ModifiedAt: now,
+		RecoveryAt: at,
+		RelationShip: order.RelationShip{
+			Customer:     sub.RelationShip.Customer,
+			Editor:       sub.RelationShip.Editor,
+			Subscription: &subID,
+		},
+		ProductsLines: lines,
+		Totals:        &totals,
+		Status:        order.StatusWaiting,
+		StatusHistory: []order.StatusChange{{
+			To:     order.StatusWaiting,
+			Editor: sub.RelationShip.Editor,
+			At:     now,
+		}},
+	}
+
+	if err := s.bookSlot(at, o.Grams(), o.ID); err != nil {
+		return err
+	}
+
+	if customer.Email != "" {
+		o.Events = []string{outbox.KindOrderCreated}
+	}
+
+	o.Ref, err = s.nextOrderRef(st, now)
+	if err == nil {
+		err = st.Order().Create(o)
+	}
+	if err != nil {
+		s.shiftSlot(at, -1, -o.Grams())
+	}
+	return err
+}
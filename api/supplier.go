@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	"github.com/valensto/api_apbp/api/formator"
+	"github.com/valensto/api_apbp/infra/repo/supplier"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Server) listSupplier() http.HandlerFunc {
+	type response struct {
+		Meta  pagination.Meta     `json:"meta"`
+		Data  []formator.JsonData `json:"data"`
+		Links map[string]string   `json:"links"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := filter.ParseQuery(r.URL.RequestURI())
+		meta, suppliers, err := s.store(r).Supplier().List(f)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "listing-suppliers", err)
+			return
+		}
+
+		var jsonSuppliers = make([]formator.JsonData, len(suppliers))
+		for i, sp := range suppliers {
+			jsonSuppliers[i] = formator.NewJSONData("suppliers", sp.ID.Hex(), api_apbp.MapSupplierToJSON(sp))
+		}
+
+		resp := response{
+			Meta:  meta,
+			Data:  jsonSuppliers,
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
+		}
+
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) getSupplier() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		sp, err := s.store(r).Supplier().Read(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "reading-supplier", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("suppliers", sp.ID.Hex(), api_apbp.MapSupplierToJSON(sp)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) createSupplier() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := api_apbp.JsonSupplier{}
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-supplier", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "supplier-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "supplier-json-validation", err)
+			return
+		}
+
+		now := time.Now()
+		sp := supplierFromJSON(req)
+		sp.ID = primitive.NewObjectID()
+		sp.CreatedAt = now
+		sp.ModifiedAt = now
+
+		err = s.store(r).Supplier().Create(sp)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "creating-supplier", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("suppliers", sp.ID.Hex(), api_apbp.MapSupplierToJSON(sp)),
+		}
+		s.respond(w, r, http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) updateSupplier() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+		req := api_apbp.JsonSupplier{}
+
+		err := s.decode(w, r, &req)
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "decoding-supplier", err)
+			return
+		}
+
+		fmtErrs, err := s.validateStruct(r, req)
+		if len(fmtErrs) > 0 {
+			s.respondErr(w, r, http.StatusBadRequest, "supplier-json-validation", fmtErrs)
+			return
+		}
+		if err != nil {
+			s.respondErr(w, r, http.StatusBadRequest, "supplier-json-validation", err)
+			return
+		}
+
+		sp, err := s.store(r).Supplier().UpdateFields(id, supplierFromJSON(req))
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "updating-supplier", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("suppliers", sp.ID.Hex(), api_apbp.MapSupplierToJSON(sp)),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func (s *Server) deleteSupplier() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.getParam(r, "id")
+
+		err := s.store(r).Supplier().Delete(id)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "deleting-supplier", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("suppliers", id, nil),
+		}
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
+func supplierFromJSON(js api_apbp.JsonSupplier) supplier.Supplier {
+	refs := make([]string, len(js.Refs))
+	for i, ref := range js.Refs {
+		refs[i] = strings.ToUpper(ref)
+	}
+
+	return supplier.Supplier{
+		Name: js.Name,
+		Contact: supplier.Contact{
+			Name:  js.Contact.Name,
+			Email: js.Contact.Email,
+			Phone: js.Contact.Phone,
+		},
+		Refs:     refs,
+		LeadTime: js.LeadTime,
+	}
+}
@@ -22,7 +22,8 @@ func (s *Server) listUser(admin bool) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		f := filter.ParseQuery(r.URL.RequestURI())
-		meta, usrs, err := s.Store.User().List(f, admin)
+		f.WithDeleted = f.WithDeleted && s.can(r, "users:admin")
+		meta, usrs, err := s.store(r).User().List(f, admin)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "listing-user", err)
 			return
@@ -36,7 +37,7 @@ func (s *Server) listUser(admin bool) http.HandlerFunc {
 		resp := response{
 			Meta:  meta,
 			Data:  jsonUsrs,
-			Links: f.Pagination.GetLinks(r.URL.RequestURI(), meta.TotalElements),
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
 		}
 
 		s.respond(w, r, http.StatusOK, resp)
@@ -52,7 +53,8 @@ func (s *Server) searchUser() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		f := filter.ParseQuery(r.URL.RequestURI())
-		meta, usrs, err := s.Store.User().List(f, false)
+		f.WithDeleted = f.WithDeleted && s.can(r, "users:admin")
+		meta, usrs, err := s.store(r).User().List(f, false)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "listing-user", err)
 			return
@@ -66,7 +68,7 @@ func (s *Server) searchUser() http.HandlerFunc {
 		resp := response{
 			Meta:  meta,
 			Data:  jsonUsrs,
-			Links: f.Pagination.GetLinks(r.URL.RequestURI(), meta.TotalElements),
+			Links: f.Pagination.Links(r.URL.RequestURI(), meta),
 		}
 
 		s.respond(w, r, http.StatusOK, resp)
@@ -82,7 +84,12 @@ func (s *Server) getUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
 
-		usr, err := s.Store.User().Read(uid)
+		if err := s.authorize(r, "users:admin", uid); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-user", err)
+			return
+		}
+
+		usr, err := s.store(r).User().Read(uid)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "reading-user", err)
 			return
@@ -91,6 +98,7 @@ func (s *Server) getUser() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("users", usr.ID.Hex(), api_apbp.MapUserToJSON(usr)),
 		}
+		s.setETag(w, usr.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -148,7 +156,7 @@ func (s *Server) createUser() http.HandlerFunc {
 			Role:      req.Role,
 		}
 
-		err = s.Store.User().Create(u)
+		err = s.store(r).User().Create(u)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "creating-user", err)
 			return
@@ -175,7 +183,12 @@ func (s *Server) updateUser() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
-		us := s.Store.User()
+
+		if err := s.authorize(r, "users:admin", uid); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-user", err)
+			return
+		}
+		us := s.store(r).User()
 
 		req := request{}
 		err := s.decode(w, r, &req)
@@ -194,7 +207,13 @@ func (s *Server) updateUser() http.HandlerFunc {
 			return
 		}
 
-		u, err := us.UpdateFields(uid, req)
+		version, err := s.ifMatch(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		u, err := us.UpdateFields(uid, req, version)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-user", err)
 			return
@@ -203,6 +222,7 @@ func (s *Server) updateUser() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("users", u.ID.Hex(), api_apbp.MapUserToJSON(u)),
 		}
+		s.setETag(w, u.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -221,7 +241,12 @@ func (s *Server) updateAddress() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
-		us := s.Store.User()
+
+		if err := s.authorize(r, "users:admin", uid); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-user", err)
+			return
+		}
+		us := s.store(r).User()
 
 		req := request{}
 		err := s.decode(w, r, &req)
@@ -247,7 +272,13 @@ func (s *Server) updateAddress() http.HandlerFunc {
 			City:       req.City,
 		}
 
-		u, err := us.UpdateField(uid, "address", addr)
+		version, err := s.ifMatch(r)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
+		u, err := us.UpdateField(uid, "address", addr, version)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-user", err)
 			return
@@ -256,6 +287,7 @@ func (s *Server) updateAddress() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("users", u.ID.Hex(), api_apbp.MapUserToJSON(u)),
 		}
+		s.setETag(w, u.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -266,10 +298,9 @@ func (s *Server) deleteUser() http.HandlerFunc {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-
 		uid := s.getParam(r, "id")
 
-		err := s.Store.User().Delete(uid)
+		err := s.store(r).User().Delete(uid)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "deleting-user", err)
 			return
@@ -282,6 +313,28 @@ func (s *Server) deleteUser() http.HandlerFunc {
 	}
 }
 
+func (s *Server) restoreUser() http.HandlerFunc {
+	type response struct {
+		Data formator.JsonData `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid := s.getParam(r, "id")
+
+		usr, err := s.store(r).User().Restore(uid)
+		if err != nil {
+			s.respondErr(w, r, http.StatusInternalServerError, "restoring-user", err)
+			return
+		}
+
+		resp := response{
+			Data: formator.NewJSONData("users", usr.ID.Hex(), api_apbp.MapUserToJSON(usr)),
+		}
+		s.setETag(w, usr.Version)
+		s.respond(w, r, http.StatusOK, resp)
+	}
+}
+
 func (s *Server) updatePwd() http.HandlerFunc {
 	type request struct {
 		OldPwd   string `json:"oldpwd" validate:"required"`
@@ -295,7 +348,12 @@ func (s *Server) updatePwd() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
-		us := s.Store.User()
+
+		if err := s.authorize(r, "users:admin", uid); err != nil {
+			s.respondErr(w, r, http.StatusForbidden, "authorizing-user", err)
+			return
+		}
+		us := s.store(r).User()
 
 		var req request
 
@@ -321,6 +379,12 @@ func (s *Server) updatePwd() http.HandlerFunc {
 			return
 		}
 
+		version, err := s.expectVersion(r, u.Version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
 		if u.Password != "" {
 			err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.OldPwd))
 			if err != nil {
@@ -335,7 +399,7 @@ func (s *Server) updatePwd() http.HandlerFunc {
 			return
 		}
 
-		u, err = us.UpdateField(uid, "password", pwd)
+		u, err = us.UpdateField(uid, "password", pwd, version)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-user", err)
 			return
@@ -344,6 +408,7 @@ func (s *Server) updatePwd() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("users", u.ID.Hex(), api_apbp.MapUserToJSON(u)),
 		}
+		s.setETag(w, u.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
@@ -360,7 +425,8 @@ func (s *Server) updateRole() http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid := s.getParam(r, "id")
-		us := s.Store.User()
+
+		us := s.store(r).User()
 
 		var req request
 
@@ -386,10 +452,16 @@ func (s *Server) updateRole() http.HandlerFunc {
 			return
 		}
 
+		version, err := s.expectVersion(r, u.Version)
+		if err != nil {
+			s.respondErr(w, r, http.StatusPreconditionFailed, "checking-version", err)
+			return
+		}
+
 		u.Role = req.Role
 		u.Email = req.Email
 
-		u, err = us.UpdateFields(uid, u)
+		u, err = us.UpdateFields(uid, u, version)
 		if err != nil {
 			s.respondErr(w, r, http.StatusInternalServerError, "updating-user", err)
 			return
@@ -398,6 +470,7 @@ func (s *Server) updateRole() http.HandlerFunc {
 		resp := response{
 			Data: formator.NewJSONData("users", u.ID.Hex(), api_apbp.MapUserToJSON(u)),
 		}
+		s.setETag(w, u.Version)
 		s.respond(w, r, http.StatusOK, resp)
 	}
 }
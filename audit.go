@@ -0,0 +1,69 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonAuditEntry struct {
+	ID         primitive.ObjectID `json:"-"`
+	At         time.Time          `json:"at"`
+	Actor      string             `json:"actor,omitempty"`
+	RequestID  string             `json:"request_id,omitempty"`
+	Resource   string             `json:"resource"`
+	ResourceID string             `json:"resource_id"`
+	Op         string             `json:"op"`
+	Changes    []JsonAuditChange  `json:"changes"`
+}
+
+type JsonAuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+func MapAuditEntryToJSON(e audit.Entry) JsonAuditEntry {
+	changes := make([]JsonAuditChange, len(e.Changes))
+	for i, c := range e.Changes {
+		changes[i] = JsonAuditChange{
+			Field:  c.Field,
+			Before: plainValue(c.Before),
+			After:  plainValue(c.After),
+		}
+	}
+
+	return JsonAuditEntry{
+		ID:         e.ID,
+		At:         e.At,
+		Actor:      e.Actor,
+		RequestID:  e.RequestID,
+		Resource:   e.Resource,
+		ResourceID: e.ResourceID,
+		Op:         e.Op,
+		Changes:    changes,
+	}
+}
+
+// plainValue turn decoded bson documents into maps so they encode as json objects
+func plainValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case primitive.D:
+		m := make(map[string]interface{}, len(t))
+		for _, e := range t {
+			m[e.Key] = plainValue(e.Value)
+		}
+		return m
+	case primitive.A:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = plainValue(e)
+		}
+		return a
+	case primitive.DateTime:
+		return t.Time()
+	default:
+		return v
+	}
+}
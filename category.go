@@ -0,0 +1,39 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/category"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonCategory struct {
+	ID          primitive.ObjectID  `json:"-"`
+	CreatedAt   time.Time           `json:"created_at,omitempty"`
+	ModifiedAt  time.Time           `json:"modified_at,omitempty"`
+	Slug        string              `json:"slug,omitempty" validate:"omitempty,max=64"`
+	Name        string              `json:"name" validate:"required"`
+	Description string              `json:"description,omitempty"`
+	Position    int                 `json:"position"`
+	Parent      *primitive.ObjectID `json:"parent,omitempty"`
+	Visible     *bool               `json:"visible,omitempty"`
+}
+
+func MapCategoryToJSON(c *category.Category) *JsonCategory {
+	if c == nil {
+		return nil
+	}
+
+	visible := c.Visible
+	return &JsonCategory{
+		ID:          c.ID,
+		CreatedAt:   c.CreatedAt,
+		ModifiedAt:  c.ModifiedAt,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+		Parent:      c.Parent,
+		Visible:     &visible,
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/valensto/api_apbp"
+	config "github.com/valensto/api_apbp/configs"
+	"github.com/valensto/api_apbp/infra/repo/outbox"
+	"github.com/valensto/api_apbp/infra/store"
+	"github.com/valensto/api_apbp/pkg/backoff"
+	"github.com/valensto/api_apbp/pkg/mailer"
+)
+
+// errNoRecipient dead-letters events whose customer cannot be mailed, retrying would not help
+var errNoRecipient = errors.New("customer has no email")
+
+// relayBatch is how many orders holding events are read at once
+const relayBatch = 100
+
+// dispatcher deliver outbox events through the mailer
+type dispatcher struct {
+	store  store.Store
+	sender mailer.Sender
+	conf   config.Outbox
+}
+
+// run poll the outbox every interval, it never returns
+func (d dispatcher) run() {
+	t := time.NewTicker(d.conf.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		d.drain()
+	}
+}
+
+// relay hand events stored along their order change to the outbox. An event is cleared
+// from its order once enqueued, a crash in between enqueues it again which the outbox ignores
+func (d dispatcher) relay() {
+	for {
+		orders, err := d.store.Order().PendingEvents(relayBatch)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		cleared := 0
+		for _, o := range orders {
+			for _, kind := range o.Events {
+				if err := d.store.Outbox().Enqueue(outbox.NewEvent(kind, "orders", o.ID)); err != nil {
+					log.Println(err)
+					continue
+				}
+				if err := d.store.Order().ClearEvent(o.ID, kind); err != nil {
+					log.Println(err)
+					continue
+				}
+				cleared++
+			}
+		}
+
+		if len(orders) < relayBatch || cleared == 0 {
+			return
+		}
+	}
+}
+
+// drain relay pending order events then deliver every due event
+func (d dispatcher) drain() {
+	d.relay()
+
+	for {
+		e, ok, err := d.store.Outbox().Claim(time.Now(), d.conf.Lease)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		d.handle(e)
+	}
+}
+
+func (d dispatcher) handle(e outbox.Event) {
+	err := d.deliver(e)
+	if err == nil {
+		err = d.store.Outbox().Sent(e.ID)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	if errors.Is(err, errNoRecipient) || e.Attempts >= d.conf.MaxAttempts {
+		log.Printf("dead-lettering %v event %v after %v attempts. err=%v\n", e.Kind, e.ID.Hex(), e.Attempts, err)
+		err = d.store.Outbox().Dead(e.ID, err.Error())
+	} else {
+		at := time.Now().Add(backoff.Exponential(e.Attempts, d.conf.Backoff, d.conf.MaxBackoff))
+		err = d.store.Outbox().Retry(e.ID, err.Error(), at)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// deliver build the mail of the event from the current order and send it
+func (d dispatcher) deliver(e outbox.Event) error {
+	o, err := d.store.Order().Read(e.ResourceID.Hex(), true)
+	if err != nil {
+		return err
+	}
+
+	order := api_apbp.MapOrderToJSON(o)
+	if order.RelationShip.Included == nil || order.RelationShip.Included.Customer.Email == "" {
+		return errNoRecipient
+	}
+
+	var mail mailer.Mail
+	switch e.Kind {
+	case outbox.KindOrderCreated:
+		customer, err := d.store.User().Read(o.RelationShip.Customer.Hex())
+		if err != nil {
+			return err
+		}
+		mail = order.NewOrderMail(customer)
+	case outbox.KindOrderReady:
+		mail = order.NewStatusMail()
+	default:
+		return fmt.Errorf("unknown event kind %v", e.Kind)
+	}
+
+	if mail.Body == nil {
+		return fmt.Errorf("cannot render %v mail", e.Kind)
+	}
+
+	return d.sender.Send(mail)
+}
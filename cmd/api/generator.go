@@ -0,0 +1,25 @@
+package main
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/api"
+	config "github.com/valensto/api_apbp/configs"
+)
+
+// generator materialize the orders of standing subscriptions ahead of their pick up
+type generator struct {
+	srv  *api.Server
+	conf config.Subscription
+}
+
+// run generate orders at start then every interval, it never returns
+func (g generator) run() {
+	t := time.NewTicker(g.conf.Interval)
+	defer t.Stop()
+
+	g.srv.GenerateOrders(time.Now(), g.conf.Horizon)
+	for range t.C {
+		g.srv.GenerateOrders(time.Now(), g.conf.Horizon)
+	}
+}
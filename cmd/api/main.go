@@ -56,6 +56,19 @@ func run() error {
 		return fmt.Errorf("error during binding database. got=%w", err)
 	}
 
+	d := dispatcher{
+		store:  srv.Store,
+		sender: mailer,
+		conf:   conf.App.Outbox,
+	}
+	go d.run()
+
+	g := generator{
+		srv:  srv,
+		conf: conf.App.Subscription,
+	}
+	go g.run()
+
 	Banner()
 
 	http.ListenAndServe(":8000", srv.Router)
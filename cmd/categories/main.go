@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	config "github.com/valensto/api_apbp/configs"
+	"github.com/valensto/api_apbp/infra/repo/category"
+	"github.com/valensto/api_apbp/infra/store"
+	"github.com/valensto/api_apbp/pkg/slug"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+		os.Exit(1)
+	}
+}
+
+// run convert the free text categories of products into category entities, names
+// sharing a slug end up in the same category. It can be run again safely
+func run() error {
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	mongoStore := store.New(conf.DB)
+
+	err = mongoStore.Open()
+	if err != nil {
+		return err
+	}
+	defer mongoStore.Close()
+
+	err = mongoStore.BindBD("apbp")
+	if err != nil {
+		return err
+	}
+
+	if err = mongoStore.Category().Migrate(); err != nil {
+		return err
+	}
+
+	names, err := mongoStore.Product().LegacyCategories()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		s := slug.Make(name)
+		if s == "" {
+			fmt.Printf("%q: skipped, no slug can be made of it\n", name)
+			continue
+		}
+
+		c, err := mongoStore.Category().ReadBySlug(s)
+		if err != nil {
+			now := time.Now()
+			c = category.Category{
+				ID:         primitive.NewObjectID(),
+				CreatedAt:  now,
+				ModifiedAt: now,
+				Slug:       s,
+				Name:       strings.TrimSpace(name),
+				Position:   i,
+				Visible:    true,
+			}
+			if err := mongoStore.Category().Create(c); err != nil {
+				return err
+			}
+		}
+
+		n, err := mongoStore.Product().AssignCategory(name, c.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%q: %d products moved to %v\n", name, n, c.Slug)
+	}
+
+	return nil
+}
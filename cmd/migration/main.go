@@ -47,6 +47,50 @@ func run() error {
 		return err
 	}
 
+	if err = mongoStore.Session().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Reset().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Stock().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Supplier().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Purchase().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Audit().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Idempotency().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Outbox().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Subscription().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Category().Migrate(); err != nil {
+		return err
+	}
+
+	if err = mongoStore.Booking().Migrate(); err != nil {
+		return err
+	}
+
 	fmt.Println(conf.App.JWTSecret)
 
 	return nil
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	config "github.com/valensto/api_apbp/configs"
+	"github.com/valensto/api_apbp/infra/store"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+		os.Exit(1)
+	}
+}
+
+// run hard delete users, products and orders soft deleted for longer than the retention period
+func run() error {
+	conf, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if conf.App.Retention <= 0 {
+		return fmt.Errorf("retention must be positive. got=%v", conf.App.Retention)
+	}
+
+	mongoStore := store.New(conf.DB)
+
+	err = mongoStore.Open()
+	if err != nil {
+		return err
+	}
+	defer mongoStore.Close()
+
+	err = mongoStore.BindBD("apbp")
+	if err != nil {
+		return err
+	}
+
+	before := time.Now().Add(-conf.App.Retention)
+
+	purges := []struct {
+		name  string
+		purge func(time.Time) (int64, error)
+	}{
+		// orders go first so that users they were the last reference to can be purged
+		{"orders", mongoStore.Order().Purge},
+		{"products", mongoStore.Product().Purge},
+		{"users", mongoStore.User().Purge},
+	}
+
+	for _, p := range purges {
+		n, err := p.purge(before)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v: %d purged\n", p.name, n)
+	}
+
+	return nil
+}
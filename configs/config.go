@@ -1,12 +1,83 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 // App is the configuration structure for the application exclude the database
 type App struct {
-	JWTSecret string `yaml:"jwtSecret"`
+	JWTSecret        string              `yaml:"jwtSecret"`
+	AccessTTL        time.Duration       `yaml:"accessTTL"`
+	RefreshTTL       time.Duration       `yaml:"refreshTTL"`
+	ResetTTL         time.Duration       `yaml:"resetTTL"`
+	ResetURL         string              `yaml:"resetURL"`
+	Roles            map[string][]string `yaml:"roles"`
+	Pickup           Pickup              `yaml:"pickup"`
+	Retention        time.Duration       `yaml:"retention"`
+	IdempotencyTTL   time.Duration       `yaml:"idempotencyTTL"`
+	IdempotencyLease time.Duration       `yaml:"idempotencyLease"`
+	OrderRefPrefix   string              `yaml:"orderRefPrefix"`
+	Outbox           Outbox              `yaml:"outbox"`
+	Subscription     Subscription        `yaml:"subscription"`
+}
+
+// Pickup is the configuration structure for pickup slots, hours are keyed by weekday
+// and max weight is in grams, zero max means unlimited
+type Pickup struct {
+	Hours      map[string][]string `yaml:"hours"`
+	SlotLength time.Duration       `yaml:"slotLength"`
+	MaxOrders  int                 `yaml:"maxOrders"`
+	MaxWeight  float64             `yaml:"maxWeight"`
+}
+
+// Outbox is the configuration structure for the notification dispatcher, the delay
+// between attempts doubles from backoff up to maxBackoff
+type Outbox struct {
+	Interval    time.Duration `yaml:"interval"`
+	Lease       time.Duration `yaml:"lease"`
+	MaxAttempts int           `yaml:"maxAttempts"`
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"maxBackoff"`
+}
+
+// Subscription is the configuration structure for the standing orders generator,
+// orders are generated horizon ahead of their pick up
+type Subscription struct {
+	Interval time.Duration `yaml:"interval"`
+	Horizon  time.Duration `yaml:"horizon"`
+}
+
+// defaultHours is the shop opening hours used when none is configured
+var defaultHours = map[string][]string{
+	"tuesday":   {"08:00-12:30"},
+	"wednesday": {"08:00-12:30"},
+	"thursday":  {"08:00-12:30"},
+	"friday":    {"08:00-12:30", "16:00-19:00"},
+	"saturday":  {"08:00-12:30"},
+}
+
+// defaultRoles is the role to permissions mapping used when none is configured
+var defaultRoles = map[string][]string{
+	"admin": {
+		"users:read", "users:write", "users:admin",
+		"products:read", "products:write",
+		"orders:read", "orders:write", "orders:admin",
+		"subscriptions:read", "subscriptions:write", "subscriptions:admin",
+		"stock:read", "stock:write",
+		"suppliers:read", "suppliers:write",
+		"purchases:read", "purchases:write",
+		"search:read",
+		"audit:read",
+		"notifications:read",
+	},
+	"customer": {
+		"users:read", "users:write",
+		"products:read",
+		"orders:read", "orders:write",
+		"subscriptions:read", "subscriptions:write",
+	},
 }
 
 // DB is the configuration structure for the database
@@ -35,6 +106,25 @@ type configuration struct {
 func Load() (configuration, error) {
 	c := &configuration{}
 
+	viper.SetDefault("app.accessTTL", "15m")
+	viper.SetDefault("app.refreshTTL", "720h")
+	viper.SetDefault("app.resetTTL", "1h")
+	viper.SetDefault("app.roles", defaultRoles)
+	viper.SetDefault("app.pickup.hours", defaultHours)
+	viper.SetDefault("app.pickup.slotLength", "30m")
+	viper.SetDefault("app.pickup.maxOrders", 10)
+	viper.SetDefault("app.retention", "2160h")
+	viper.SetDefault("app.idempotencyTTL", "24h")
+	viper.SetDefault("app.idempotencyLease", "1m")
+	viper.SetDefault("app.orderRefPrefix", "CMD")
+	viper.SetDefault("app.outbox.interval", "5s")
+	viper.SetDefault("app.outbox.lease", "1m")
+	viper.SetDefault("app.outbox.maxAttempts", 8)
+	viper.SetDefault("app.outbox.backoff", "30s")
+	viper.SetDefault("app.outbox.maxBackoff", "1h")
+	viper.SetDefault("app.subscription.interval", "1h")
+	viper.SetDefault("app.subscription.horizon", "168h")
+
 	viper.SetConfigName(".env")
 	viper.AddConfigPath("./")
 	err := viper.ReadInConfig()
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Operations recorded in the audit log
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// redacted replaces the values of sensitive fields
+const redacted = "[redacted]"
+
+// Entry is an append-only record of a mutation on a resource
+type Entry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	At         time.Time          `bson:"at"`
+	Actor      string             `bson:"actor,omitempty"`
+	RequestID  string             `bson:"request_id,omitempty"`
+	Resource   string             `bson:"resource"`
+	ResourceID string             `bson:"resource_id"`
+	Op         string             `bson:"op"`
+	Changes    []Change           `bson:"changes"`
+}
+
+// Change is the before and after value of a top level field
+type Change struct {
+	Field  string      `bson:"field"`
+	Before interface{} `bson:"before"`
+	After  interface{} `bson:"after"`
+}
+
+// Actor identifies who made a request
+type Actor struct {
+	User      string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor return a copy of ctx carrying the actor of the mutations made with it
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFrom return the actor carried by ctx, zero if none
+func ActorFrom(ctx context.Context) Actor {
+	a, _ := ctx.Value(actorKey{}).(Actor)
+	return a
+}
+
+// ADB represents audit repository interface
+type ADB interface {
+	Migrate() error
+
+	List(f filter.Query) (pagination.Meta, []Entry, error)
+}
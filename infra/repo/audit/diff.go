@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ignored fields change on every write and are left out of diffs
+var ignored = map[string]bool{
+	"modified_at": true,
+	"score":       true,
+}
+
+// Diff return the top level fields which differ between two documents,
+// either may be nil. Values of redact fields are never recorded.
+func Diff(before, after interface{}, redact ...string) ([]Change, error) {
+	b, err := toM(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toM(after)
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[string]bool, len(redact))
+	for _, f := range redact {
+		hidden[f] = true
+	}
+
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+
+	changes := []Change{}
+	for k := range keys {
+		if ignored[k] || reflect.DeepEqual(b[k], a[k]) {
+			continue
+		}
+
+		c := Change{Field: k, Before: b[k], After: a[k]}
+		if hidden[k] {
+			c.Before, c.After = mask(b[k]), mask(a[k])
+		}
+		changes = append(changes, c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+func mask(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redacted
+}
+
+func toM(doc interface{}) (bson.M, error) {
+	m := bson.M{}
+	if doc == nil {
+		return m, nil
+	}
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return m, err
+	}
+	return m, bson.Unmarshal(raw, &m)
+}
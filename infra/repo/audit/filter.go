@@ -0,0 +1,31 @@
+package audit
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// schema lists fields audit entries can be filtered and sorted by
+var schema = filter.Schema{
+	"at":            {Kind: filter.Date, Sortable: true},
+	"actor":         {Kind: filter.String},
+	"request_id":    {Kind: filter.String},
+	"resource":      {Kind: filter.String},
+	"resource_id":   {Kind: filter.String},
+	"op":            {Kind: filter.String},
+	"changes.field": {Kind: filter.String},
+}
+
+func listPipe(f filter.Query) (mongo.Pipeline, error) {
+	var pipeline mongo.Pipeline
+
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return mongorepo.PagePipeline(pipeline, f, schema, bson.D{primitive.E{Key: "at", Value: -1}})
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"at", "resource", "resource_id", "op", "changes"},
+	"properties": bson.M{
+		"at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"actor": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string",
+		},
+		"request_id": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string",
+		},
+		"resource": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"resource_id": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"op": bson.M{
+			"enum":        []string{OpCreate, OpUpdate, OpDelete},
+			"description": "must be a valid operation and is required",
+		},
+		"changes": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array and is required",
+			"items": bson.M{
+				"bsonType": "object",
+				"required": []string{"field"},
+				"properties": bson.M{
+					"field": bson.M{
+						"bsonType":    "string",
+						"description": "must be a string and is required",
+					},
+				},
+			},
+		},
+	},
+}
+
+// Migrate create audit collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, collection, bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.col.Indexes().CreateMany(r.ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "resource", Value: 1},
+				primitive.E{Key: "resource_id", Value: 1},
+				primitive.E{Key: "at", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "actor", Value: 1},
+				primitive.E{Key: "at", Value: -1},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const collection = "audit"
+
+// Repo is a representation of audit repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new audit repository
+func NewRepo(ctx context.Context, db *mongo.Database) ADB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection(collection)
+	return r
+}
+
+// List return a list of audit entries
+func (r Repo) List(f filter.Query) (pagination.Meta, []Entry, error) {
+	res := struct {
+		Entries []Entry                  `bson:"data"`
+		Meta    []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := listPipe(f)
+	if err != nil {
+		return meta, res.Entries, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Entries, repo.ErrRepoOp{
+			Op:   "audit-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during audit aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Entries, repo.ErrRepoOp{
+			Op:   "retrieving-audit",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving audit. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Entries, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Entries, repo.ErrRepoOp{
+			Op:   "retrieving-audit",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Entries, nil
+}
+
+// Recorder appends the mutations of one resource type to the audit log
+type Recorder struct {
+	col      *mongo.Collection
+	ctx      context.Context
+	resource string
+	redact   []string
+}
+
+// NewRecorder return a recorder of resource mutations, the actor is read from ctx
+func NewRecorder(ctx context.Context, db *mongo.Database, resource string, redact ...string) Recorder {
+	return Recorder{
+		col:      db.Collection(collection),
+		ctx:      ctx,
+		resource: resource,
+		redact:   redact,
+	}
+}
+
+// Created record the creation of doc
+func (r Recorder) Created(id primitive.ObjectID, doc interface{}) {
+	r.record(OpCreate, id, nil, doc)
+}
+
+// Updated record the changes between before and after
+func (r Recorder) Updated(id primitive.ObjectID, before, after interface{}) {
+	r.record(OpUpdate, id, before, after)
+}
+
+// Deleted record the deletion of doc
+func (r Recorder) Deleted(id primitive.ObjectID, before interface{}) {
+	r.record(OpDelete, id, before, nil)
+}
+
+// record never fails the mutation it follows, errors are logged
+func (r Recorder) record(op string, id primitive.ObjectID, before, after interface{}) {
+	changes, err := Diff(before, after, r.redact...)
+	if err != nil {
+		log.Printf("cannot diff %v %v for audit. err=%v\n", r.resource, id.Hex(), err)
+		return
+	}
+
+	if op == OpUpdate && len(changes) == 0 {
+		return
+	}
+
+	actor := ActorFrom(r.ctx)
+	e := Entry{
+		At:         time.Now(),
+		Actor:      actor.User,
+		RequestID:  actor.RequestID,
+		Resource:   r.resource,
+		ResourceID: id.Hex(),
+		Op:         op,
+		Changes:    changes,
+	}
+
+	if _, err := r.col.InsertOne(r.ctx, e); err != nil {
+		log.Printf("cannot record audit of %v %v. err=%v\n", r.resource, id.Hex(), err)
+	}
+}
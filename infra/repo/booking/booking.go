@@ -0,0 +1,28 @@
+package booking
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUncounted is returned when a slot has no booking count yet, it has to be seeded
+// from the orders already picked up in it
+var ErrUncounted = errors.New("pickup slot bookings are not counted yet")
+
+// Booking structure representation of the orders booked in a pickup slot, weight is in grams.
+// Counts expire with their slot
+type Booking struct {
+	Start     time.Time `bson:"_id"`
+	Orders    int       `bson:"orders"`
+	Weight    float64   `bson:"weight"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// BDB represents slot booking repository interface
+type BDB interface {
+	Migrate() error
+
+	Book(start time.Time, weight float64, maxOrders int, maxWeight float64) error
+	Seed(b Booking) error
+	Shift(start time.Time, orders int, weight float64) error
+}
@@ -0,0 +1,44 @@
+package booking
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"orders", "weight", "expires_at"},
+	"properties": bson.M{
+		"orders": bson.M{
+			"bsonType":    "int",
+			"description": "must be an int and is required",
+		},
+		"weight": bson.M{
+			"bsonType":    "double",
+			"description": "must be a double and is required",
+		},
+		"expires_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+	},
+}
+
+// Migrate create slot_bookings collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, collection, bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.col.Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
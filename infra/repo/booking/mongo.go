@@ -0,0 +1,102 @@
+package booking
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/slot"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const collection = "slot_bookings"
+
+// Repo is a representation of slot booking repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new slot booking repository
+func NewRepo(ctx context.Context, db *mongo.Database) BDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection(collection)
+	return r
+}
+
+// Book atomically count an order of weight grams in the slot starting at start if it still
+// fits, zero max means unlimited. It returns slot.ErrFull when it doesn't and ErrUncounted
+// when the slot has no count yet
+func (r Repo) Book(start time.Time, weight float64, maxOrders int, maxWeight float64) error {
+	filter := bson.M{"_id": start}
+	if maxOrders > 0 {
+		filter["orders"] = bson.M{"$lt": maxOrders}
+	}
+	if maxWeight > 0 {
+		filter["weight"] = bson.M{"$lte": maxWeight - weight}
+	}
+
+	res, err := r.col.UpdateOne(r.ctx, filter, bson.M{"$inc": bson.M{"orders": 1, "weight": weight}})
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "booking-slot",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during booking slot %v. got=%w", start, err),
+		}
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	n, err := r.col.CountDocuments(r.ctx, bson.M{"_id": start})
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "booking-slot",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during booking slot %v. got=%w", start, err),
+		}
+	}
+	if n == 0 {
+		return ErrUncounted
+	}
+	return slot.ErrFull
+}
+
+// Seed store the first count of a slot, it returns repo.ErrDuplicate when the slot was counted meanwhile
+func (r Repo) Seed(b Booking) error {
+	_, err := r.col.InsertOne(r.ctx, b)
+	if mongorepo.IsDuplicate(err) {
+		return repo.ErrDuplicate
+	}
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "seeding-slot-booking",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during seeding slot %v. got=%w", b.Start, err),
+		}
+	}
+	return nil
+}
+
+// Shift move the count of the slot starting at start by orders and weight grams,
+// a slot not counted yet is left as is since it will be seeded from its orders
+func (r Repo) Shift(start time.Time, orders int, weight float64) error {
+	update := bson.M{"$inc": bson.M{"orders": orders, "weight": weight}}
+
+	if _, err := r.col.UpdateOne(r.ctx, bson.M{"_id": start}, update); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "shifting-slot-booking",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during shifting slot %v. got=%w", start, err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package category
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category structure representation, categories are listed by position then name
+type Category struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at"`
+	ModifiedAt  time.Time           `bson:"modified_at"`
+	Slug        string              `bson:"slug"`
+	Name        string              `bson:"name"`
+	Description string              `bson:"description,omitempty"`
+	Position    int                 `bson:"position"`
+	Parent      *primitive.ObjectID `bson:"parent,omitempty"`
+	Visible     bool                `bson:"visible"`
+}
+
+// CDB represents category repository interface
+type CDB interface {
+	Migrate() error
+
+	Read(id string) (Category, error)
+	ReadBySlug(slug string) (Category, error)
+	List(f filter.Query) (pagination.Meta, []Category, error)
+	Children(id primitive.ObjectID) ([]Category, error)
+	Create(c Category) error
+	UpdateFields(id string, upd Category) (Category, error)
+	Delete(id string) error
+}
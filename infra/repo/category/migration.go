@@ -0,0 +1,69 @@
+package category
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"slug", "name", "position", "visible"},
+	"properties": bson.M{
+		"slug": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"name": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"description": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string",
+		},
+		"position": bson.M{
+			"bsonType":    "int",
+			"description": "must be an int and is required",
+		},
+		"parent": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be an objectId",
+		},
+		"visible": bson.M{
+			"bsonType":    "bool",
+			"description": "must be a bool and is required",
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"modified_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+// Migrate create categories collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "categories", bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("categories").Indexes().CreateMany(r.ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"slug": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"parent": 1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
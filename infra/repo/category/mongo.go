@@ -0,0 +1,244 @@
+package category
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a representation of category repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new category repository
+func NewRepo(ctx context.Context, db *mongo.Database) CDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("categories")
+	return r
+}
+
+// List return a list of categories
+func (r Repo) List(f filter.Query) (pagination.Meta, []Category, error) {
+	res := struct {
+		Categories []Category               `bson:"data"`
+		Meta       []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := listPipe(f)
+	if err != nil {
+		return meta, res.Categories, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Categories, repo.ErrRepoOp{
+			Op:   "category-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during category aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Categories, repo.ErrRepoOp{
+			Op:   "retrieving-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving category. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Categories, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Categories, repo.ErrRepoOp{
+			Op:   "retrieving-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Categories, nil
+}
+
+// Children return the categories directly under the category
+func (r Repo) Children(id primitive.ObjectID) ([]Category, error) {
+	var cs []Category
+
+	opts := options.Find().SetSort(bson.D{
+		primitive.E{Key: "position", Value: 1},
+		primitive.E{Key: "name", Value: 1},
+	})
+
+	curs, err := r.col.Find(r.ctx, bson.M{"parent": id}, opts)
+	if err != nil {
+		return cs, repo.ErrRepoOp{
+			Op:   "retrieving-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving category. got=%w", err),
+		}
+	}
+
+	if err := curs.All(r.ctx, &cs); err != nil {
+		return cs, repo.ErrRepoOp{
+			Op:   "retrieving-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving category. got=%w", err),
+		}
+	}
+
+	return cs, nil
+}
+
+// Read return category by id
+func (r Repo) Read(id string) (Category, error) {
+	c := Category{}
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c, repo.ErrRepoOp{
+			Op:   "parsing-category-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&c); err != nil {
+		return c, repo.ErrRepoOp{
+			Op:   "retrieving-category",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving category. got=%w", err),
+		}
+	}
+	return c, nil
+}
+
+// ReadBySlug return category by slug
+func (r Repo) ReadBySlug(slug string) (Category, error) {
+	c := Category{}
+	if err := r.col.FindOne(r.ctx, bson.M{"slug": slug}).Decode(&c); err != nil {
+		return c, repo.ErrRepoOp{
+			Op:   "retrieving-category",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("category not found. slug=%v doesn't exist", slug),
+		}
+	}
+	return c, nil
+}
+
+// Create category to repo
+func (r Repo) Create(c Category) error {
+	_, err := r.col.InsertOne(r.ctx, c)
+	if mongorepo.IsDuplicate(err) {
+		return repo.ErrRepoOp{
+			Op:   "create-category",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("%w. slug=%v already exists", repo.ErrDuplicate, c.Slug),
+		}
+	}
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "create-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// UpdateFields category from repo
+func (r Repo) UpdateFields(id string, upd Category) (Category, error) {
+	var c Category
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c, repo.ErrRepoOp{
+			Op:   "parsing-category-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	set := bson.M{
+		"slug":        upd.Slug,
+		"name":        upd.Name,
+		"description": upd.Description,
+		"position":    upd.Position,
+		"visible":     upd.Visible,
+		"modified_at": time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if upd.Parent != nil {
+		set["parent"] = upd.Parent
+	} else {
+		update["$unset"] = bson.M{"parent": ""}
+	}
+
+	opts := options.FindOneAndUpdate()
+	after := options.After
+	opts.ReturnDocument = &after
+
+	err = r.col.FindOneAndUpdate(r.ctx, bson.M{"_id": uid}, update, opts).Decode(&c)
+	if mongorepo.IsDuplicate(err) {
+		return c, repo.ErrRepoOp{
+			Op:   "updating-category",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("%w. slug=%v already exists", repo.ErrDuplicate, upd.Slug),
+		}
+	}
+	if err != nil {
+		return c, repo.ErrRepoOp{
+			Op:   "updating-category",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	return c, nil
+}
+
+// Delete category by id
+func (r Repo) Delete(id string) error {
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "parsing-category-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	if _, err := r.col.DeleteOne(r.ctx, bson.M{"_id": uid}); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "deleting-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during deleting category. got=%w", err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package counter
+
+// Counter structure representation of a named sequence
+type Counter struct {
+	Name string `bson:"_id"`
+	Seq  int    `bson:"seq"`
+}
+
+// CDB represents counter repository interface
+type CDB interface {
+	Next(name string) (int, error)
+}
@@ -0,0 +1,49 @@
+package counter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a representation of counter repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new counter repository
+func NewRepo(ctx context.Context, db *mongo.Database) CDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("counters")
+	return r
+}
+
+// Next atomically increment the named counter, created on first use, and return its new value
+func (r Repo) Next(name string) (int, error) {
+	var c Counter
+
+	opts := options.FindOneAndUpdate().SetUpsert(true)
+	after := options.After
+	opts.ReturnDocument = &after
+
+	update := bson.M{"$inc": bson.M{"seq": 1}}
+	if err := r.col.FindOneAndUpdate(r.ctx, bson.M{"_id": name}, update, opts).Decode(&c); err != nil {
+		return 0, repo.ErrRepoOp{
+			Op:   "incrementing-counter",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during incrementing counter %v. got=%w", name, err),
+		}
+	}
+
+	return c.Seq, nil
+}
@@ -0,0 +1,6 @@
+package repo
+
+import "errors"
+
+// ErrDuplicate is returned when a document breaks a unique index
+var ErrDuplicate = errors.New("document already exists")
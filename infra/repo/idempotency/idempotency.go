@@ -0,0 +1,30 @@
+package idempotency
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Key structure representation of an idempotency key, status is zero while its request is processed
+// and reserved until is when a pending key is considered abandoned by the request that reserved it
+type Key struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	User          string             `bson:"user"`
+	Key           string             `bson:"key"`
+	RequestHash   string             `bson:"request_hash"`
+	Status        int                `bson:"status"`
+	Body          []byte             `bson:"body,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	ReservedUntil time.Time          `bson:"reserved_until"`
+	ExpiresAt     time.Time          `bson:"expires_at"`
+}
+
+// IDB represents idempotency key repository interface
+type IDB interface {
+	Migrate() error
+
+	Reserve(k Key) (Key, bool, error)
+	Complete(k Key, status int, body []byte) error
+	Release(k Key) error
+}
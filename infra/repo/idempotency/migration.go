@@ -0,0 +1,74 @@
+package idempotency
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"user", "key", "request_hash", "status", "created_at", "expires_at"},
+	"properties": bson.M{
+		"user": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"key": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"request_hash": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"status": bson.M{
+			"bsonType":    "int",
+			"description": "must be an int and is required",
+		},
+		"body": bson.M{
+			"bsonType":    "binData",
+			"description": "must be binary data",
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"reserved_until": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"expires_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+	},
+}
+
+// Migrate create idempotency_keys collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "idempotency_keys", bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("idempotency_keys").Indexes().CreateMany(r.ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "user", Value: 1},
+				primitive.E{Key: "key", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
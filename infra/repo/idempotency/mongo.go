@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a representation of idempotency key repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new idempotency key repository
+func NewRepo(ctx context.Context, db *mongo.Database) IDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("idempotency_keys")
+	return r
+}
+
+// Reserve store the key unless the user already used it, reserved reports whether it was stored.
+// A pending key whose lease ended before k was created is taken over when the request is the same,
+// its request having died without completing or releasing it. Otherwise the existing key is returned.
+func (r Repo) Reserve(k Key) (Key, bool, error) {
+	var existing Key
+	if k.ID.IsZero() {
+		k.ID = primitive.NewObjectID()
+	}
+	// dates are stored to the millisecond, the lease is matched as stored by Complete and Release
+	k.ReservedUntil = k.ReservedUntil.Truncate(time.Millisecond)
+
+	filter := bson.M{"user": k.User, "key": k.Key}
+	opts := options.FindOneAndUpdate().SetUpsert(true)
+
+	err := r.col.FindOneAndUpdate(r.ctx, filter, bson.M{"$setOnInsert": k}, opts).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return k, true, nil
+	}
+	if err != nil {
+		return existing, false, repo.ErrRepoOp{
+			Op:   "reserving-idempotency-key",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during reserving idempotency key. got=%w", err),
+		}
+	}
+
+	if existing.Status != 0 || existing.RequestHash != k.RequestHash || existing.ReservedUntil.After(k.CreatedAt) {
+		return existing, false, nil
+	}
+
+	// keys reserved before leases existed have none and are taken over as well
+	filter = bson.M{
+		"_id":            existing.ID,
+		"status":         0,
+		"reserved_until": bson.M{"$not": bson.M{"$gt": k.CreatedAt}},
+	}
+	update := bson.M{"$set": bson.M{"reserved_until": k.ReservedUntil, "expires_at": k.ExpiresAt}}
+	after := options.After
+	opts = options.FindOneAndUpdate()
+	opts.ReturnDocument = &after
+
+	var taken Key
+	err = r.col.FindOneAndUpdate(r.ctx, filter, update, opts).Decode(&taken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// another request took it over or completed it meanwhile
+		return existing, false, nil
+	}
+	if err != nil {
+		return existing, false, repo.ErrRepoOp{
+			Op:   "reserving-idempotency-key",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during taking over idempotency key. got=%w", err),
+		}
+	}
+
+	return taken, true, nil
+}
+
+// Complete store the response of the request the key was reserved for, unless the key was taken over
+func (r Repo) Complete(k Key, status int, body []byte) error {
+	update := bson.M{"$set": bson.M{"status": status, "body": body}}
+
+	if _, err := r.col.UpdateOne(r.ctx, leased(k), update); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "completing-idempotency-key",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during completing idempotency key. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// Release delete a reserved key so that its request can be retried, unless the key was taken over
+func (r Repo) Release(k Key) error {
+	if _, err := r.col.DeleteOne(r.ctx, leased(k)); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "releasing-idempotency-key",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during releasing idempotency key. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// leased match the key only while it holds the lease it was reserved with
+func leased(k Key) bson.M {
+	return bson.M{"_id": k.ID, "status": 0, "reserved_until": k.ReservedUntil}
+}
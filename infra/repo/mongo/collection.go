@@ -0,0 +1,30 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// namespaceExists is the server error code of creating a collection that already exists
+const namespaceExists = 48
+
+// CreateCollection create the collection with its validator, an existing collection gets its
+// validator updated instead so migrations can run again on a deployed database
+func CreateCollection(ctx context.Context, db *mongo.Database, name string, validator bson.M) error {
+	err := db.CreateCollection(ctx, name, options.CreateCollection().SetValidator(validator))
+
+	var ce mongo.CommandError
+	if !errors.As(err, &ce) || ce.Code != namespaceExists {
+		return err
+	}
+
+	return db.RunCommand(ctx, bson.D{
+		primitive.E{Key: "collMod", Value: name},
+		primitive.E{Key: "validator", Value: validator},
+	}).Err()
+}
@@ -0,0 +1,144 @@
+package mongo
+
+import (
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PagePipeline append sort and pagination stages, keyset ones when the query has a cursor
+func PagePipeline(pipeline mongo.Pipeline, f filter.Query, s filter.Schema, fallback bson.D) (mongo.Pipeline, error) {
+	if f.Pagination.Keyset {
+		return CursorPipeline(pipeline, f, s, fallback)
+	}
+
+	pipeline, err := SortPipeline(pipeline, f, s, fallback)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return PaginatePipeline(pipeline, f.Pagination), nil
+}
+
+// CursorPipeline append keyset pagination stages on the first sort key and _id.
+// It doesn't count documents, meta only tells if there is more on the way.
+func CursorPipeline(pipeline mongo.Pipeline, f filter.Query, s filter.Schema, fallback bson.D) (mongo.Pipeline, error) {
+	if err := s.Check(filter.Query{Sort: f.Sort}); err != nil {
+		return pipeline, errFilter(err)
+	}
+
+	key, dir := "_id", 1
+	if len(f.Sort) > 0 {
+		key = f.Sort[0].Field
+		if f.Sort[0].Desc {
+			dir = -1
+		}
+	} else if len(fallback) > 0 {
+		key = fallback[0].Key
+		dir, _ = fallback[0].Value.(int)
+	}
+
+	// cursors can only be made of whitelisted keys, relevance score included
+	if _, ok := s[key]; !ok || dir == 0 {
+		key, dir = "_id", 1
+	}
+
+	var c *pagination.Cursor
+	if f.Pagination.Cursor != "" {
+		cursor, err := pagination.DecodeCursor(f.Pagination.Cursor)
+		if err != nil {
+			return pipeline, errFilter(err)
+		}
+		c = &cursor
+	}
+
+	back := c != nil && c.Prev
+	if back {
+		dir = -dir
+	}
+
+	if c != nil {
+		match, err := after(key, dir, *c, s)
+		if err != nil {
+			return pipeline, err
+		}
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: match}})
+	}
+
+	sort := bson.D{primitive.E{Key: key, Value: dir}}
+	if key != "_id" {
+		sort = append(sort, primitive.E{Key: "_id", Value: dir})
+	}
+
+	limit := f.Pagination.Limit
+	page := bson.M{"$slice": bson.A{"$data", limit}}
+	if back {
+		page = bson.M{"$reverseArray": page}
+	}
+
+	bound := func(i int) bson.M {
+		return bson.M{
+			"id": bson.M{"$toString": bson.M{"$arrayElemAt": bson.A{"$data._id", i}}},
+			"v":  bson.M{"$toString": bson.M{"$arrayElemAt": bson.A{"$data." + key, i}}},
+		}
+	}
+
+	ds := []bson.D{
+		{primitive.E{Key: "$sort", Value: sort}},
+		{primitive.E{Key: "$limit", Value: limit + 1}},
+		{primitive.E{Key: "$group", Value: bson.M{"_id": nil, "data": bson.M{"$push": "$$ROOT"}}}},
+		{primitive.E{Key: "$project", Value: bson.M{
+			"data": page,
+			"more": bson.M{"$gt": bson.A{bson.M{"$size": "$data"}, limit}},
+		}}},
+		{primitive.E{Key: "$project", Value: bson.M{
+			"data": 1,
+			"meta": bson.A{bson.M{
+				"perPages": bson.M{"$literal": limit},
+				"keyset": bson.M{
+					"more":  "$more",
+					"back":  bson.M{"$literal": back},
+					"from":  bson.M{"$literal": c != nil},
+					"first": bound(0),
+					"last":  bound(-1),
+				},
+			}},
+		}}},
+	}
+
+	return append(pipeline, ds...), nil
+}
+
+// after return the condition of documents coming after the cursor in the dir order
+func after(key string, dir int, c pagination.Cursor, s filter.Schema) (bson.M, error) {
+	cmp := "$gt"
+	if dir < 0 {
+		cmp = "$lt"
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, errFilter(err)
+	}
+
+	if key == "_id" {
+		return bson.M{"_id": bson.M{cmp: id}}, nil
+	}
+
+	v, err := s[key].Parse(filter.OpEq, c.Value)
+	if err != nil {
+		return nil, errFilter(err)
+	}
+	if s[key].Kind == filter.ID {
+		if v, err = objectIDs(v); err != nil {
+			return nil, errFilter(err)
+		}
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{key: bson.M{cmp: v}},
+		bson.M{key: v, "_id": bson.M{cmp: id}},
+	}}, nil
+}
@@ -0,0 +1,30 @@
+package mongo
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKey is the server error code of unique index violations
+const duplicateKey = 11000
+
+// IsDuplicate reports whether err is a unique index violation, from a write or a find and modify
+func IsDuplicate(err error) bool {
+	var ce mongo.CommandError
+	if errors.As(err, &ce) {
+		return ce.Code == duplicateKey
+	}
+
+	var we mongo.WriteException
+	if !errors.As(err, &we) {
+		return false
+	}
+
+	for _, e := range we.WriteErrors {
+		if e.Code == duplicateKey {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package mongo
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FilterPipeline append a $match stage built from query filters the schema allows
+func FilterPipeline(pipeline mongo.Pipeline, f filter.Query, s filter.Schema) (mongo.Pipeline, error) {
+	if err := s.Check(f); err != nil {
+		return pipeline, errFilter(err)
+	}
+
+	if len(f.Filters) == 0 {
+		return pipeline, nil
+	}
+
+	match := bson.M{}
+	for _, c := range f.Filters {
+		field := s[c.Field]
+
+		v, err := field.Parse(c.Op, c.Value)
+		if err != nil {
+			return pipeline, errFilter(fmt.Errorf("invalid value for %v. got=%w", c.Field, err))
+		}
+
+		if field.Kind == filter.ID {
+			if v, err = objectIDs(v); err != nil {
+				return pipeline, errFilter(fmt.Errorf("invalid value for %v. got=%w", c.Field, err))
+			}
+		}
+
+		ops, ok := match[c.Field].(bson.M)
+		if !ok {
+			ops = bson.M{}
+			match[c.Field] = ops
+		}
+		ops["$"+c.Op] = v
+	}
+
+	return append(pipeline, bson.D{primitive.E{Key: "$match", Value: match}}), nil
+}
+
+// SortPipeline append a $sort stage from query sort the schema allows or the fallback one
+func SortPipeline(pipeline mongo.Pipeline, f filter.Query, s filter.Schema, fallback bson.D) (mongo.Pipeline, error) {
+	if err := s.Check(filter.Query{Sort: f.Sort}); err != nil {
+		return pipeline, errFilter(err)
+	}
+
+	sort := fallback
+	if len(f.Sort) > 0 {
+		sort = bson.D{}
+		for _, sf := range f.Sort {
+			dir := 1
+			if sf.Desc {
+				dir = -1
+			}
+			sort = append(sort, primitive.E{Key: sf.Field, Value: dir})
+		}
+	}
+
+	if len(sort) == 0 {
+		return pipeline, nil
+	}
+
+	return append(pipeline, bson.D{primitive.E{Key: "$sort", Value: sort}}), nil
+}
+
+func objectIDs(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		return primitive.ObjectIDFromHex(t)
+	case []interface{}:
+		ids := make([]interface{}, len(t))
+		for i, s := range t {
+			id, err := objectIDs(s)
+			if err != nil {
+				return nil, err
+			}
+			ids[i] = id
+		}
+		return ids, nil
+	}
+	return v, nil
+}
+
+func errFilter(err error) error {
+	return repo.ErrRepoOp{
+		Op:   "parsing-filter",
+		Code: http.StatusBadRequest,
+		Err:  err,
+	}
+}
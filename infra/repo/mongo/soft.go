@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeletedAt is the field marking soft deleted documents, it is unset on alive ones
+const DeletedAt = "deleted_at"
+
+// Alive restrict filter to documents which are not soft deleted
+func Alive(filter bson.M) bson.M {
+	filter[DeletedAt] = nil
+	return filter
+}
+
+// AlivePipeline append a match on documents which are not soft deleted, unless withDeleted
+func AlivePipeline(pipeline mongo.Pipeline, withDeleted bool) mongo.Pipeline {
+	if withDeleted {
+		return pipeline
+	}
+	return append(pipeline, bson.D{primitive.E{Key: "$match", Value: Alive(bson.M{})}})
+}
+
+// SoftDelete mark an alive document as deleted and return it as it was before
+func SoftDelete(ctx context.Context, col *mongo.Collection, id primitive.ObjectID) *mongo.SingleResult {
+	now := time.Now()
+	update := Bump(bson.M{"$set": bson.M{DeletedAt: now, "modified_at": now}})
+
+	return col.FindOneAndUpdate(ctx, Alive(bson.M{"_id": id}), update)
+}
+
+// Restore unmark a soft deleted document and return it restored
+func Restore(ctx context.Context, col *mongo.Collection, id primitive.ObjectID) *mongo.SingleResult {
+	filter := bson.M{"_id": id, DeletedAt: bson.M{"$ne": nil}}
+	update := Bump(bson.M{
+		"$unset": bson.M{DeletedAt: ""},
+		"$set":   bson.M{"modified_at": time.Now()},
+	})
+
+	opts := options.FindOneAndUpdate()
+	after := options.After
+	opts.ReturnDocument = &after
+
+	return col.FindOneAndUpdate(ctx, filter, update, opts)
+}
+
+// Purge hard delete documents soft deleted before the given time
+func Purge(ctx context.Context, col *mongo.Collection, before time.Time) (int64, error) {
+	res, err := col.DeleteMany(ctx, bson.M{DeletedAt: bson.M{"$lte": before}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,47 @@
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TextLanguage is the language text indexes stem words with, they also ignore case and diacritics
+const TextLanguage = "french"
+
+// TextIndex return a text index on the given fields, weights rank matches on some fields higher
+func TextIndex(weights bson.D) mongo.IndexModel {
+	keys := bson.D{}
+	for _, w := range weights {
+		keys = append(keys, primitive.E{Key: w.Key, Value: "text"})
+	}
+
+	return mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetDefaultLanguage(TextLanguage).SetWeights(weights),
+	}
+}
+
+// TextPipeline append a $text match on the collection text index and its relevance as score field.
+// It must be the first stage of the pipeline.
+func TextPipeline(pipeline mongo.Pipeline, term string) mongo.Pipeline {
+	if term == "" {
+		return pipeline
+	}
+
+	ds := []bson.D{
+		{primitive.E{Key: "$match", Value: bson.M{"$text": bson.M{"$search": term}}}},
+		{primitive.E{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+	}
+
+	return append(pipeline, ds...)
+}
+
+// TextSort return the sort by relevance when searching a term or the fallback one
+func TextSort(term string, fallback bson.D) bson.D {
+	if term == "" {
+		return fallback
+	}
+	return bson.D{primitive.E{Key: "score", Value: -1}}
+}
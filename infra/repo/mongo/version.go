@@ -0,0 +1,57 @@
+package mongo
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Version is the field incremented on every update of a document. Documents at
+// version 0 have none, so setting a whole document with omitempty never rewinds it
+const Version = "version"
+
+// Expect restrict filter to documents at the expected version, unless repo.AnyVersion
+func Expect(filter bson.M, version int) bson.M {
+	switch version {
+	case repo.AnyVersion:
+	case 0:
+		filter[Version] = bson.M{"$in": bson.A{0, nil}}
+	default:
+		filter[Version] = version
+	}
+	return filter
+}
+
+// BumpStage return an update pipeline stage incrementing the document version
+func BumpStage() bson.D {
+	return bson.D{primitive.E{
+		Key: "$set",
+		Value: bson.D{primitive.E{
+			Key:   Version,
+			Value: bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + Version, 0}}, 1}},
+		}},
+	}}
+}
+
+// Bump add the version increment to an update document
+func Bump(update bson.M) bson.M {
+	update["$inc"] = bson.M{Version: 1}
+	return update
+}
+
+// Stale reports whether a document at current does not match the expected version
+func Stale(version, current int) bool {
+	return version != repo.AnyVersion && version != current
+}
+
+// VersionErr return the precondition failed error of an update expecting version on a document at current
+func VersionErr(op string, version, current int) error {
+	return repo.ErrRepoOp{
+		Op:   op,
+		Code: http.StatusPreconditionFailed,
+		Err:  fmt.Errorf("%w. expected=%d got=%d", repo.ErrVersion, version, current),
+	}
+}
@@ -10,34 +10,114 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func listPipe(uid primitive.ObjectID, f filter.Query) mongo.Pipeline {
+// schema lists fields orders can be filtered and sorted by
+var schema = filter.Schema{
+	"ref":                   {Kind: filter.String, Sortable: true},
+	"status":                {Kind: filter.String, Sortable: true},
+	"recovery_at":           {Kind: filter.Date, Sortable: true},
+	"created_at":            {Kind: filter.Date, Sortable: true},
+	"modified_at":           {Kind: filter.Date, Sortable: true},
+	"relationShip.customer": {Kind: filter.ID},
+	"relationShip.editor":   {Kind: filter.ID},
+	"products.ref":          {Kind: filter.String},
+	"totals.total":          {Kind: filter.Number, Sortable: true},
+}
+
+func listPipe(uid, customer primitive.ObjectID, f filter.Query) (mongo.Pipeline, error) {
 	var pipeline mongo.Pipeline
 
 	if uid != primitive.NilObjectID {
 		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.D{primitive.E{Key: "_id", Value: uid}}}})
+		pipeline = mongorepo.AlivePipeline(pipeline, false)
 		pipeline = populatePipeline(pipeline, f.Populate)
-		return pipeline
+		return pipeline, nil
 	}
 
-	pipeline = searchTerm(pipeline, f.Term)
+	pipeline = mongorepo.TextPipeline(pipeline, f.Term)
+	pipeline = mongorepo.AlivePipeline(pipeline, f.WithDeleted)
+	pipeline = customerPipeline(pipeline, customer)
+
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
 
 	// pipeline = recoveryPipeline(pipeline, f.Interval)
 	pipeline = populatePipeline(pipeline, f.Populate)
-	pipeline = mongorepo.PaginatePipeline(pipeline, f.Pagination)
+
+	return mongorepo.PagePipeline(pipeline, f, schema, mongorepo.TextSort(f.Term, nil))
+}
+
+func forecastPipe(f filter.Query, statuses []string) mongo.Pipeline {
+	pipeline := forecastMatch(f, statuses)
+
+	pipeline = append(pipeline, forecastGroup(nil))
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "quantity", Value: -1}}}})
 
 	return pipeline
 }
 
-func forecastPipe(f filter.Query, confirm bool) mongo.Pipeline {
-	var pipeline mongo.Pipeline
+func forecastSeriesPipe(f filter.Query, statuses []string, groupBy string) mongo.Pipeline {
+	pipeline := forecastMatch(f, statuses)
+
+	if groupBy == GroupByCategory {
+		ds := []bson.D{
+			{primitive.E{Key: "$lookup", Value: bson.D{primitive.E{Key: "from", Value: "products"}, primitive.E{Key: "localField", Value: "products.ref"}, primitive.E{Key: "foreignField", Value: "ref"}, primitive.E{Key: "as", Value: "product"}}}},
+			{primitive.E{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$product"}, primitive.E{Key: "preserveNullAndEmptyArrays", Value: true}}}},
+			{primitive.E{Key: "$lookup", Value: bson.D{primitive.E{Key: "from", Value: "categories"}, primitive.E{Key: "localField", Value: "product.category_id"}, primitive.E{Key: "foreignField", Value: "_id"}, primitive.E{Key: "as", Value: "category"}}}},
+			{primitive.E{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$category"}, primitive.E{Key: "preserveNullAndEmptyArrays", Value: true}}}},
+		}
+		pipeline = append(pipeline, ds...)
+	}
+
+	pipeline = append(pipeline, forecastGroup(bucketExpr(f, groupBy)))
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "quantity", Value: -1}}}})
+
+	pipeline = append(pipeline, bson.D{primitive.E{
+		Key: "$group",
+		Value: bson.D{
+			primitive.E{Key: "_id", Value: "$_id.bucket"},
+			primitive.E{Key: "quantity", Value: bson.M{"$sum": "$quantity"}},
+			primitive.E{Key: "net", Value: bson.M{"$sum": "$net"}},
+			primitive.E{Key: "grams", Value: bson.M{"$sum": "$grams"}},
+			primitive.E{Key: "pieces", Value: bson.M{"$sum": "$pieces"}},
+			primitive.E{Key: "products", Value: bson.M{"$push": bson.M{
+				"_id":      bson.M{"ref": "$_id.ref", "name": "$_id.name"},
+				"quantity": "$quantity",
+				"net":      "$net",
+				"grams":    "$grams",
+				"pieces":   "$pieces",
+			}}},
+		},
+	}})
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "_id", Value: 1}}}})
 
+	return pipeline
+}
+
+// forecastStatuses return the statuses of orders a forecast counts, nil for every order not cancelled
+func forecastStatuses(confirm bool) []string {
 	if confirm {
-		match := bson.D{primitive.E{
-			Key:   "$match",
-			Value: bson.M{"status": "confirm"},
-		}}
-		pipeline = append(pipeline, match)
+		return []string{StatusConfirm}
 	}
+	return nil
+}
+
+// forecastMatch select products lines of orders in one of the statuses to pick up in the range,
+// every order not cancelled without statuses
+func forecastMatch(f filter.Query, statuses []string) mongo.Pipeline {
+	var pipeline mongo.Pipeline
+
+	status := mongorepo.Alive(bson.M{"status": bson.M{"$ne": StatusCancelled}})
+	if len(statuses) > 0 {
+		status = mongorepo.Alive(bson.M{"status": bson.M{"$in": statuses}})
+	}
+
+	match := bson.D{primitive.E{
+		Key:   "$match",
+		Value: status,
+	}}
+	pipeline = append(pipeline, match)
 
 	rangeStage := bson.D{primitive.E{Key: "$match", Value: bson.M{
 		"recovery_at": bson.M{
@@ -53,78 +133,96 @@ func forecastPipe(f filter.Query, confirm bool) mongo.Pipeline {
 		Value: "$products",
 	}}
 
-	pipeline = append(pipeline, unwindStage)
+	pipeline = append(pipeline, unwindStage, expandStage())
 
-	groupStage := bson.D{primitive.E{
-		Key: "$group",
-		Value: bson.D{
-			primitive.E{
-				Key: "_id",
-				Value: bson.D{
-					primitive.E{
-						Key:   "ref",
-						Value: "$products.ref",
-					},
-					primitive.E{
-						Key:   "name",
-						Value: "$products.name",
-					},
-				},
-			},
-			primitive.E{
-				Key: "quantity",
-				Value: bson.D{primitive.E{
-					Key: "$sum",
-					Value: bson.D{primitive.E{
-						Key: "$ceil",
-						Value: bson.D{primitive.E{
-							Key: "$cond",
-							Value: bson.D{
-								primitive.E{
-									Key: "if",
-									Value: bson.D{primitive.E{
-										Key: "$eq",
-										Value: bson.A{
-											"$products.unit",
-											"gr",
-										},
-									}},
-								},
-								primitive.E{
-									Key:   "then",
-									Value: "$products.quantity",
-								},
-								primitive.E{
-									Key: "else",
-									Value: bson.D{primitive.E{
-										Key: "$multiply",
-										Value: bson.A{
-											"$products.quantity",
-											"$products.auw",
-										},
-									}},
-								},
-							},
-						}},
-					}},
-				}},
-			},
-		},
+	return append(pipeline, unwindStage)
+}
+
+// expandStage replace bundle lines by their components scaled to the bundle pieces ordered,
+// other lines are kept in a one line array for the following unwind
+func expandStage() bson.D {
+	pieces := bson.M{"$cond": bson.M{
+		"if":   bson.M{"$eq": bson.A{"$products.unit", "p"}},
+		"then": "$products.quantity",
+		"else": bson.M{"$divide": bson.A{
+			"$products.quantity",
+			bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$products.auw", 0}}, "$products.auw", 1}},
+		}},
 	}}
 
-	pipeline = append(pipeline, groupStage)
+	return bson.D{primitive.E{Key: "$addFields", Value: bson.M{"products": bson.M{"$cond": bson.M{
+		"if": bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$products.components", bson.A{}}}}, 0}},
+		"then": bson.M{"$map": bson.M{
+			"input": "$products.components",
+			"as":    "c",
+			"in": bson.M{
+				"ref":      "$$c.ref",
+				"name":     "$$c.name",
+				"unit":     "$$c.unit",
+				"auw":      "$$c.auw",
+				"quantity": bson.M{"$multiply": bson.A{"$$c.quantity", pieces}},
+			},
+		}},
+		"else": bson.A{"$products"},
+	}}}}}
+}
 
-	sortStage := bson.D{primitive.E{
-		Key: "$sort",
-		Value: bson.D{primitive.E{
-			Key:   "quantity",
-			Value: -1,
+// forecastGroup sum products lines by product and bucket if any.
+// net is the ceiled weight in grams with pieces converted using the average unit weight,
+// quantity is the ceiled raw weight to buy: the requested net weight of gram lines converted
+// back to raw with the yield of the chosen option, pieces being raw already.
+// grams and pieces are the quantities as ordered in each unit.
+func forecastGroup(bucket interface{}) bson.D {
+	id := bson.D{
+		primitive.E{Key: "ref", Value: "$products.ref"},
+		primitive.E{Key: "name", Value: "$products.name"},
+	}
+	if bucket != nil {
+		id = append(id, primitive.E{Key: "bucket", Value: bucket})
+	}
+
+	isGrams := bson.M{"$eq": bson.A{"$products.unit", "gr"}}
+	net := bson.M{"$cond": bson.M{
+		"if":   isGrams,
+		"then": "$products.quantity",
+		"else": bson.M{"$multiply": bson.A{"$products.quantity", "$products.auw"}},
+	}}
+	raw := bson.M{"$cond": bson.M{
+		"if": isGrams,
+		"then": bson.M{"$divide": bson.A{
+			bson.M{"$multiply": bson.A{net, 100}},
+			bson.M{"$ifNull": bson.A{"$products.option.yield", 100}},
 		}},
+		"else": net,
 	}}
 
-	pipeline = append(pipeline, sortStage)
+	return bson.D{primitive.E{
+		Key: "$group",
+		Value: bson.D{
+			primitive.E{Key: "_id", Value: id},
+			primitive.E{Key: "quantity", Value: bson.M{"$sum": bson.M{"$ceil": raw}}},
+			primitive.E{Key: "net", Value: bson.M{"$sum": bson.M{"$ceil": net}}},
+			primitive.E{Key: "grams", Value: bson.M{"$sum": bson.M{"$cond": bson.A{isGrams, "$products.quantity", 0}}}},
+			primitive.E{Key: "pieces", Value: bson.M{"$sum": bson.M{"$cond": bson.A{isGrams, 0, "$products.quantity"}}}},
+		},
+	}}
+}
 
-	return pipeline
+// bucketExpr return the expression orders are bucketed by, dates use the range start offset
+func bucketExpr(f filter.Query, groupBy string) interface{} {
+	tz := f.Range.Start.Local().Format("-07:00")
+
+	switch groupBy {
+	case GroupByDay:
+		return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$recovery_at", "timezone": tz}}
+	case GroupByWeek:
+		return bson.M{"$dateToString": bson.M{"format": "%G-W%V", "date": "$recovery_at", "timezone": tz}}
+	case GroupByCategory:
+		return bson.M{"$ifNull": bson.A{"$category.slug", ""}}
+	case GroupByStatus:
+		return "$status"
+	}
+	return nil
 }
 
 func recoveryPipeline(pipeline mongo.Pipeline, i *filter.Range) mongo.Pipeline {
@@ -140,6 +238,34 @@ func recoveryPipeline(pipeline mongo.Pipeline, i *filter.Range) mongo.Pipeline {
 	}}})
 }
 
+func customerPipeline(pipeline mongo.Pipeline, customer primitive.ObjectID) mongo.Pipeline {
+	if customer == primitive.NilObjectID {
+		return pipeline
+	}
+
+	return append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.M{
+		"relationShip.customer": customer,
+	}}})
+}
+
+func preparationPipe(rg filter.Range) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: mongorepo.Alive(bson.M{
+			"status": bson.M{"$nin": []string{StatusCancelled, StatusDelivered}},
+			"recovery_at": bson.M{
+				"$gte": rg.Start,
+				"$lte": rg.End,
+			},
+		})}},
+		{primitive.E{Key: "$sort", Value: bson.D{
+			primitive.E{Key: "recovery_at", Value: 1},
+			primitive.E{Key: "ref", Value: 1},
+		}}},
+	}
+
+	return populatePipeline(pipeline, true)
+}
+
 func populatePipeline(pipeline mongo.Pipeline, populate bool) mongo.Pipeline {
 	if !populate {
 		return pipeline
@@ -147,9 +273,9 @@ func populatePipeline(pipeline mongo.Pipeline, populate bool) mongo.Pipeline {
 
 	ds := []bson.D{
 		{primitive.E{Key: "$lookup", Value: bson.D{primitive.E{Key: "from", Value: "users"}, primitive.E{Key: "localField", Value: "relationShip.customer"}, primitive.E{Key: "foreignField", Value: "_id"}, primitive.E{Key: "as", Value: "relationShip.included.customer"}}}},
-		{primitive.E{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$relationShip.included.customer"}, primitive.E{Key: "preserveNullAndEmptyArrays", Value: false}}}},
+		{primitive.E{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$relationShip.included.customer"}, primitive.E{Key: "preserveNullAndEmptyArrays", Value: true}}}},
 		{primitive.E{Key: "$lookup", Value: bson.D{primitive.E{Key: "from", Value: "users"}, primitive.E{Key: "localField", Value: "relationShip.editor"}, primitive.E{Key: "foreignField", Value: "_id"}, primitive.E{Key: "as", Value: "relationShip.included.editor"}}}},
-		{primitive.E{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$relationShip.included.editor"}, primitive.E{Key: "preserveNullAndEmptyArrays", Value: false}}}},
+		{primitive.E{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$relationShip.included.editor"}, primitive.E{Key: "preserveNullAndEmptyArrays", Value: true}}}},
 	}
 
 	for _, d := range ds {
@@ -158,32 +284,3 @@ func populatePipeline(pipeline mongo.Pipeline, populate bool) mongo.Pipeline {
 
 	return pipeline
 }
-
-func searchTerm(pipeline mongo.Pipeline, str string) mongo.Pipeline {
-	if str != "" {
-		pipeline = append(pipeline, bson.D{primitive.E{
-			Key: "$match",
-			Value: bson.D{primitive.E{
-				Key: "$or",
-				Value: bson.A{
-					bson.D{primitive.E{
-						Key: "ref",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-					bson.D{primitive.E{
-						Key: "status",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-				},
-			}},
-		}})
-	}
-
-	return pipeline
-}
@@ -1,7 +1,12 @@
 package order
 
 import (
+	"fmt"
+
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/infra/repo/product"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -22,6 +27,15 @@ var jsonSchema = bson.M{
 			"bsonType":    "date",
 			"description": "must be a date and is required",
 		},
+		"deleted_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"version": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int",
+		},
 		"recovery_at": bson.M{
 			"bsonType":    "date",
 			"description": "must be a string and is required",
@@ -39,6 +53,10 @@ var jsonSchema = bson.M{
 					"bsonType":    "objectId",
 					"description": "must be a objectId and is required",
 				},
+				"subscription": bson.M{
+					"bsonType":    "objectId",
+					"description": "must be a objectId",
+				},
 			},
 		},
 		"products": bson.M{
@@ -71,13 +89,89 @@ var jsonSchema = bson.M{
 						"bsonType":    "double",
 						"description": "must be a string and is required",
 					},
+					"price": bson.M{
+						"bsonType":    "object",
+						"description": "must be an object",
+					},
+					"option": product.OptionSchema,
+					"components": bson.M{
+						"bsonType":    "array",
+						"description": "must be an array",
+						"items": bson.M{
+							"bsonType":    "object",
+							"description": "must be an object",
+							"required":    []string{"ref", "name", "quantity", "unit", "auw"},
+						},
+					},
+					"actual_weight": bson.M{
+						"bsonType":    "double",
+						"description": "must be a double",
+					},
 				},
 			},
 		},
 		"status": bson.M{
-			"enum":        []string{"waiting", "confirm", "ready", "delivered"},
+			"enum":        Statuses,
 			"description": "must be a string and is required",
 		},
+		"totals": bson.M{
+			"bsonType":    "object",
+			"description": "must be an object",
+			"required":    []string{"subtotal", "vat", "total"},
+			"properties": bson.M{
+				"currency": bson.M{
+					"bsonType":    "string",
+					"description": "must be a string",
+				},
+				"subtotal": bson.M{
+					"bsonType":    "long",
+					"description": "must be a long and is required",
+				},
+				"vat": bson.M{
+					"bsonType":    "array",
+					"description": "must be an array and is required",
+				},
+				"total": bson.M{
+					"bsonType":    "long",
+					"description": "must be a long and is required",
+				},
+			},
+		},
+		"events": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array",
+			"items": bson.M{
+				"bsonType":    "string",
+				"description": "must be a string",
+			},
+		},
+		"status_history": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array",
+			"items": bson.M{
+				"bsonType":    "object",
+				"description": "must be an object",
+				"required":    []string{"from", "to", "editor", "at"},
+				"properties": bson.M{
+					"from": bson.M{
+						"bsonType":    "string",
+						"description": "must be a string and is required",
+					},
+					"to": bson.M{
+						"enum":        Statuses,
+						"description": "must be a string and is required",
+					},
+					"editor": bson.M{
+						"bsonType":    "objectId",
+						"description": "must be a objectId and is required",
+					},
+					"at": bson.M{
+						"bsonType":    "date",
+						"description": "must be a date and is required",
+					},
+				},
+			},
+		},
 	},
 }
 
@@ -87,12 +181,20 @@ var validator = bson.M{
 
 // Migrate create product collection with schema and indexs
 func (r *Repo) Migrate() error {
-	opts := options.CreateCollection().SetValidator(validator)
-	if err := r.db.CreateCollection(r.ctx, "orders", opts); err != nil {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "orders", validator); err != nil {
 		return err
 	}
 
-	_, err := r.db.Collection("orders").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+	// refs given before the daily counter may collide and would make the unique index build fail
+	dups, err := r.duplicateRefs()
+	if err != nil {
+		return err
+	}
+	if len(dups) > 0 {
+		return fmt.Errorf("orders share refs %v, give them distinct refs before migrating", dups)
+	}
+
+	_, err = r.db.Collection("orders").Indexes().CreateOne(r.ctx, mongo.IndexModel{
 		Keys:    bson.M{"ref": 1},
 		Options: options.Index().SetUnique(true),
 	})
@@ -100,5 +202,63 @@ func (r *Repo) Migrate() error {
 		return err
 	}
 
+	// a subscription generates one order per pick up time
+	_, err = r.db.Collection("orders").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "relationShip.subscription", Value: 1},
+			primitive.E{Key: "recovery_at", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"relationShip.subscription": bson.M{"$exists": true},
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// only orders with events left to relay are indexed
+	_, err = r.db.Collection("orders").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys:    bson.M{"events": 1},
+		Options: options.Index().SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("orders").Indexes().CreateOne(r.ctx, mongorepo.TextIndex(bson.D{
+		primitive.E{Key: "ref", Value: 10},
+		primitive.E{Key: "products.name", Value: 4},
+		primitive.E{Key: "status", Value: 1},
+	}))
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// duplicateRefs return up to ten refs shared by several orders
+func (r *Repo) duplicateRefs() ([]string, error) {
+	var refs []string
+
+	curs, err := r.db.Collection("orders").Aggregate(r.ctx, mongo.Pipeline{
+		{primitive.E{Key: "$group", Value: bson.M{"_id": "$ref", "count": bson.M{"$sum": 1}}}},
+		{primitive.E{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{primitive.E{Key: "$limit", Value: 10}},
+	})
+	if err != nil {
+		return refs, err
+	}
+
+	var res []struct {
+		Ref string `bson:"_id"`
+	}
+	if err := curs.All(r.ctx, &res); err != nil {
+		return refs, err
+	}
+
+	for _, d := range res {
+		refs = append(refs, d.Ref)
+	}
+	return refs, nil
+}
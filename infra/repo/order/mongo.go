@@ -2,12 +2,15 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,9 +21,10 @@ import (
 
 // Repo is a representation of order repository structure
 type Repo struct {
-	db  *mongo.Database
-	ctx context.Context
-	col *mongo.Collection
+	db    *mongo.Database
+	ctx   context.Context
+	col   *mongo.Collection
+	audit audit.Recorder
 }
 
 // NewRepo return a new order repository
@@ -30,12 +34,13 @@ func NewRepo(ctx context.Context, db *mongo.Database) ODB {
 		ctx: ctx,
 	}
 	r.col = r.db.Collection("orders")
+	r.audit = audit.NewRecorder(ctx, db, "orders")
 	return r
 }
 
-// List return a list of orders
-func (r Repo) List(f filter.Query) (pagination.Meta, []Order, error) {
-	total, orders, err := r.retrieve(primitive.NilObjectID, f)
+// List return a list of orders, restricted to customer orders unless customer is nil
+func (r Repo) List(f filter.Query, customer primitive.ObjectID) (pagination.Meta, []Order, error) {
+	total, orders, err := r.retrieve(primitive.NilObjectID, customer, f)
 	if err != nil {
 		return total, orders, err
 	}
@@ -55,7 +60,7 @@ func (r Repo) Read(id string, populate bool) (Order, error) {
 		}
 	}
 
-	_, orders, err := r.retrieve(uid, filter.Query{Populate: populate})
+	_, orders, err := r.retrieve(uid, primitive.NilObjectID, filter.Query{Populate: populate})
 	if err != nil {
 		return order, err
 	}
@@ -63,7 +68,7 @@ func (r Repo) Read(id string, populate bool) (Order, error) {
 	if len(orders) <= 0 {
 		return order, repo.ErrRepoOp{
 			Op:   "retrieving-order",
-			Code: http.StatusBadRequest,
+			Code: http.StatusNotFound,
 			Err:  fmt.Errorf("order not found. id=%v doesn't exist", id),
 		}
 	}
@@ -71,11 +76,69 @@ func (r Repo) Read(id string, populate bool) (Order, error) {
 
 }
 
+// ReadByRef return order by its reference
+func (r Repo) ReadByRef(ref string, populate bool) (Order, error) {
+	var o Order
+
+	opts := options.FindOne().SetProjection(bson.M{"_id": 1})
+	if err := r.col.FindOne(r.ctx, mongorepo.Alive(bson.M{"ref": ref}), opts).Decode(&o); err != nil {
+		return o, repo.ErrRepoOp{
+			Op:   "retrieving-order",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("order not found. ref=%v doesn't exist", ref),
+		}
+	}
+
+	return r.Read(o.ID.Hex(), populate)
+}
+
 // Forecast calculate product quantity needed
 func (r Repo) Forecast(f filter.Query, confirm bool) ([]Forecast, error) {
+	return r.forecast(forecastPipe(f, forecastStatuses(confirm)))
+}
+
+// ForecastCommitted calculate product quantity needed by confirmed and ready orders, the ones
+// still to hand over which customers committed to
+func (r Repo) ForecastCommitted(f filter.Query) ([]Forecast, error) {
+	return r.forecast(forecastPipe(f, []string{StatusConfirm, StatusReady}))
+}
+
+func (r Repo) forecast(pipeline mongo.Pipeline) ([]Forecast, error) {
 	var fs []Forecast
 
-	curs, err := r.col.Aggregate(r.ctx, forecastPipe(f, confirm))
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return fs, repo.ErrRepoOp{
+			Op:   "order-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during order aggregation. got=%w", err),
+		}
+	}
+
+	if err := curs.All(r.ctx, &fs); err != nil {
+		return fs, repo.ErrRepoOp{
+			Op:   "order-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during order retrieving. got=%w", err),
+		}
+	}
+
+	return fs, nil
+}
+
+// ForecastSeries calculate product quantity needed by day, week, category or status
+func (r Repo) ForecastSeries(f filter.Query, confirm bool, groupBy string) ([]ForecastBucket, error) {
+	var fs []ForecastBucket
+
+	if bucketExpr(f, groupBy) == nil {
+		return fs, repo.ErrRepoOp{
+			Op:   "parsing-group-by",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("group_by must be one of %v. got=%v", GroupBys, groupBy),
+		}
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, forecastSeriesPipe(f, forecastStatuses(confirm), groupBy))
 	if err != nil {
 		return fs, repo.ErrRepoOp{
 			Op:   "order-aggregation",
@@ -95,7 +158,83 @@ func (r Repo) Forecast(f filter.Query, confirm bool) ([]Forecast, error) {
 	return fs, nil
 }
 
-func (r Repo) retrieve(uid primitive.ObjectID, f filter.Query) (pagination.Meta, []Order, error) {
+// Preparation return populated orders still to hand over in the range, by pick up time
+func (r Repo) Preparation(rg filter.Range) ([]Order, error) {
+	var os []Order
+
+	curs, err := r.col.Aggregate(r.ctx, preparationPipe(rg))
+	if err != nil {
+		return os, repo.ErrRepoOp{
+			Op:   "order-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during preparation aggregation. got=%w", err),
+		}
+	}
+
+	if err := curs.All(r.ctx, &os); err != nil {
+		return os, repo.ErrRepoOp{
+			Op:   "retrieving-preparation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving preparation. got=%w", err),
+		}
+	}
+
+	return os, nil
+}
+
+// Generated reports whether the subscription already has an order picked up at the given time,
+// deleted ones included as the unique index counts them
+func (r Repo) Generated(sub primitive.ObjectID, at time.Time) (bool, error) {
+	n, err := r.col.CountDocuments(r.ctx, bson.M{
+		"relationShip.subscription": sub,
+		"recovery_at":               at,
+	})
+	if err != nil {
+		return false, repo.ErrRepoOp{
+			Op:   "retrieving-generated-order",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving generated order. got=%w", err),
+		}
+	}
+	return n > 0, nil
+}
+
+// Pickups return orders to pick up in the range which are not cancelled, exclude order is left out
+func (r Repo) Pickups(rg filter.Range, exclude primitive.ObjectID) ([]Order, error) {
+	var os []Order
+
+	filter := mongorepo.Alive(bson.M{
+		"status": bson.M{"$ne": StatusCancelled},
+		"_id":    bson.M{"$ne": exclude},
+		"recovery_at": bson.M{
+			"$gte": rg.Start,
+			"$lt":  rg.End,
+		},
+	})
+
+	opts := options.Find().SetProjection(bson.M{"recovery_at": 1, "products": 1})
+
+	curs, err := r.col.Find(r.ctx, filter, opts)
+	if err != nil {
+		return os, repo.ErrRepoOp{
+			Op:   "retrieving-pickups",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving pickups. got=%w", err),
+		}
+	}
+
+	if err := curs.All(r.ctx, &os); err != nil {
+		return os, repo.ErrRepoOp{
+			Op:   "retrieving-pickups",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving pickups. got=%w", err),
+		}
+	}
+
+	return os, nil
+}
+
+func (r Repo) retrieve(uid, customer primitive.ObjectID, f filter.Query) (pagination.Meta, []Order, error) {
 	res := struct {
 		Orders []Order                  `bson:"data"`
 		Meta   []map[string]interface{} `bson:"meta"`
@@ -103,7 +242,12 @@ func (r Repo) retrieve(uid primitive.ObjectID, f filter.Query) (pagination.Meta,
 
 	meta := pagination.Meta{}
 
-	curs, err := r.col.Aggregate(r.ctx, listPipe(uid, f))
+	pipeline, err := listPipe(uid, customer, f)
+	if err != nil {
+		return meta, res.Orders, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
 	if err != nil {
 		return meta, res.Orders, repo.ErrRepoOp{
 			Op:   "order-aggregation",
@@ -154,7 +298,14 @@ func (r Repo) retrieve(uid primitive.ObjectID, f filter.Query) (pagination.Meta,
 
 // Create order to repo
 func (r Repo) Create(usr Order) error {
-	_, err := r.col.InsertOne(r.ctx, usr)
+	res, err := r.col.InsertOne(r.ctx, usr)
+	if mongorepo.IsDuplicate(err) {
+		return repo.ErrRepoOp{
+			Op:   "create-order",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("%w. got=%v", repo.ErrDuplicate, err),
+		}
+	}
 	if err != nil {
 		return repo.ErrRepoOp{
 			Op:   "create-order",
@@ -162,11 +313,14 @@ func (r Repo) Create(usr Order) error {
 			Err:  fmt.Errorf("error occured during creating. got=%w", err),
 		}
 	}
+
+	uid, _ := res.InsertedID.(primitive.ObjectID)
+	r.audit.Created(uid, usr)
 	return nil
 }
 
 // UpdateFields order from repo
-func (r Repo) UpdateFields(id string, upd interface{}) (Order, error) {
+func (r Repo) UpdateFields(id string, upd interface{}, version int) (Order, error) {
 	update := []bson.D{
 		{primitive.E{
 			Key:   "$set",
@@ -181,20 +335,11 @@ func (r Repo) UpdateFields(id string, upd interface{}) (Order, error) {
 		}},
 	}
 
-	u, err := r.update(id, update)
-	if err != nil {
-		return u, repo.ErrRepoOp{
-			Op:   "updating-order",
-			Code: http.StatusBadRequest,
-			Err:  fmt.Errorf("error occured during updating. got=%w", err),
-		}
-	}
-
-	return u, nil
+	return r.update(id, nil, update, version)
 }
 
 // UpdateField order from repo
-func (r Repo) UpdateField(id, field string, v interface{}) (Order, error) {
+func (r Repo) UpdateField(id, field string, v interface{}, version int) (Order, error) {
 	update := []bson.D{
 		{primitive.E{
 			Key: "$set",
@@ -215,19 +360,211 @@ func (r Repo) UpdateField(id, field string, v interface{}) (Order, error) {
 		}},
 	}
 
-	u, err := r.update(id, update)
+	return r.update(id, nil, update, version)
+}
+
+// UpdateStatus move order to status if the transition is allowed and record it in status history,
+// events are stored along in the same update for the dispatcher to relay
+func (r Repo) UpdateStatus(id, status string, editor primitive.ObjectID, version int, events ...string) (Order, error) {
+	o, err := r.Read(id, false)
+	if err != nil {
+		return o, err
+	}
+
+	if mongorepo.Stale(version, o.Version) {
+		return o, mongorepo.VersionErr("updating-order-status", version, o.Version)
+	}
+
+	change, err := Transition(o.Status, status, editor)
+	if err != nil {
+		return o, err
+	}
+
+	update := []bson.D{
+		{primitive.E{
+			Key: "$set",
+			Value: bson.D{
+				primitive.E{
+					Key: "status",
+					Value: bson.D{primitive.E{
+						Key:   "$literal",
+						Value: change.To,
+					}},
+				},
+				primitive.E{
+					Key: "status_history",
+					Value: bson.D{primitive.E{
+						Key: "$concatArrays",
+						Value: bson.A{
+							bson.D{primitive.E{Key: "$ifNull", Value: bson.A{"$status_history", bson.A{}}}},
+							bson.A{bson.D{primitive.E{Key: "$literal", Value: change}}},
+						},
+					}},
+				},
+			},
+		}},
+	}
+	if len(events) > 0 {
+		update = append(update, bson.D{primitive.E{
+			Key: "$set",
+			Value: bson.D{primitive.E{
+				Key: "events",
+				Value: bson.D{primitive.E{
+					Key: "$concatArrays",
+					Value: bson.A{
+						bson.D{primitive.E{Key: "$ifNull", Value: bson.A{"$events", bson.A{}}}},
+						bson.D{primitive.E{Key: "$literal", Value: events}},
+					},
+				}},
+			}},
+		}})
+	}
+	update = append(update, bson.D{primitive.E{
+		Key: "$addFields",
+		Value: bson.D{primitive.E{
+			Key:   "modified_at",
+			Value: change.At,
+		}},
+	}})
+
+	// matching on the previous status prevents concurrent transitions from both succeeding
+	u, err := r.update(id, bson.M{"status": change.From}, update, version)
+	if errors.Is(err, repo.ErrVersion) {
+		return u, err
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the order was read above, it either left the previous status or was deleted meanwhile
+		if _, rerr := r.Read(id, false); rerr != nil {
+			return u, rerr
+		}
+		return u, repo.ErrRepoOp{
+			Op:   "updating-order-status",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("order status changed during update. got=%w", err),
+		}
+	}
+	if err != nil {
+		return u, repo.ErrRepoOp{
+			Op:   "updating-order-status",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during updating order status. got=%w", err),
+		}
+	}
+
+	return u, nil
+}
+
+// PendingEvents return live orders holding events not yet handed to the outbox
+func (r Repo) PendingEvents(limit int64) ([]Order, error) {
+	var orders []Order
+
+	opts := options.Find().SetLimit(limit).SetProjection(bson.M{"_id": 1, "events": 1})
+	curs, err := r.col.Find(r.ctx, mongorepo.Alive(bson.M{"events": bson.M{"$exists": true}}), opts)
+	if err == nil {
+		err = curs.All(r.ctx, &orders)
+	}
+	if err != nil {
+		return orders, repo.ErrRepoOp{
+			Op:   "reading-order-events",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during reading pending events. got=%w", err),
+		}
+	}
+
+	return orders, nil
+}
+
+// ClearEvent remove a relayed event from its order. It is dispatcher bookkeeping,
+// so the order version is left untouched and no audit entry is written
+func (r Repo) ClearEvent(id primitive.ObjectID, kind string) error {
+	update := []bson.D{
+		{primitive.E{
+			Key: "$set",
+			Value: bson.M{"events": bson.M{"$filter": bson.M{
+				"input": "$events",
+				"cond":  bson.M{"$ne": bson.A{"$$this", kind}},
+			}}},
+		}},
+		{primitive.E{
+			Key: "$set",
+			Value: bson.M{"events": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$size": "$events"}, 0}},
+				"$$REMOVE",
+				"$events",
+			}}},
+		}},
+	}
+
+	if _, err := r.col.UpdateOne(r.ctx, bson.M{"_id": id, "events": kind}, update); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "clearing-order-event",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during clearing %v event of order %v. got=%w", kind, id.Hex(), err),
+		}
+	}
+	return nil
+}
+
+// weighRetries is how many times weighing without version is retried when the order changes meanwhile
+const weighRetries = 3
+
+// UpdateLineWeight record weighed quantity of a product line of an order in preparation and its totals
+// in a single update. Totals are computed from the order as read, so the update expects its version
+func (r Repo) UpdateLineWeight(id string, index int, weight float32, version int) (Order, error) {
+	for attempt := 1; ; attempt++ {
+		o, err := r.weighLine(id, index, weight, version)
+		if errors.Is(err, repo.ErrVersion) && version == repo.AnyVersion && attempt < weighRetries {
+			continue
+		}
+		return o, err
+	}
+}
+
+func (r Repo) weighLine(id string, index int, weight float32, version int) (Order, error) {
+	o, err := r.Read(id, false)
+	if err != nil {
+		return o, err
+	}
+
+	if mongorepo.Stale(version, o.Version) {
+		return o, mongorepo.VersionErr("updating-order-weight", version, o.Version)
+	}
+
+	if index < 0 || index >= len(o.ProductsLines) || (o.Status != StatusConfirm && o.Status != StatusReady) {
+		return o, repo.ErrRepoOp{
+			Op:   "updating-order-weight",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("only existing lines of orders in preparation can be weighed"),
+		}
+	}
+
+	o.ProductsLines[index].ActualWeight = &weight
+	update := bson.M{
+		"$set": bson.M{
+			fmt.Sprintf("products.%d.actual_weight", index): weight,
+			"totals":      ComputeTotals(o.ProductsLines),
+			"modified_at": time.Now(),
+		},
+	}
+
+	cond := bson.M{"status": bson.M{"$in": bson.A{StatusConfirm, StatusReady}}}
+
+	u, err := r.update(id, cond, update, o.Version)
+	if errors.Is(err, repo.ErrVersion) {
+		return u, err
+	}
 	if err != nil {
 		return u, repo.ErrRepoOp{
-			Op:   "updating-order",
-			Code: http.StatusBadRequest,
-			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+			Op:   "updating-order-weight",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("only existing lines of orders in preparation can be weighed. got=%w", err),
 		}
 	}
 
 	return u, nil
 }
 
-func (r Repo) update(id string, update []bson.D) (Order, error) {
+func (r Repo) update(id string, cond bson.M, update interface{}, version int) (Order, error) {
 	var o Order
 
 	uid, err := primitive.ObjectIDFromHex(id)
@@ -239,7 +576,31 @@ func (r Repo) update(id string, update []bson.D) (Order, error) {
 		}
 	}
 
-	filter := bson.M{"_id": bson.M{"$eq": uid}, "deleted_at": nil}
+	filter := mongorepo.Alive(bson.M{"_id": bson.M{"$eq": uid}})
+	for k, v := range cond {
+		filter[k] = v
+	}
+
+	var before Order
+	if err := r.col.FindOne(r.ctx, filter).Decode(&before); err != nil {
+		return o, repo.ErrRepoOp{
+			Op:   "updating-order",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	if mongorepo.Stale(version, before.Version) {
+		return o, mongorepo.VersionErr("updating-order", version, before.Version)
+	}
+	filter = mongorepo.Expect(filter, version)
+
+	switch u := update.(type) {
+	case []bson.D:
+		update = append(u, mongorepo.BumpStage())
+	case bson.M:
+		update = mongorepo.Bump(u)
+	}
 
 	opts := options.FindOneAndUpdate()
 	after := options.After
@@ -251,6 +612,9 @@ func (r Repo) update(id string, update []bson.D) (Order, error) {
 		update,
 		opts,
 	)
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) && version != repo.AnyVersion {
+		return o, mongorepo.VersionErr("updating-order", version, before.Version)
+	}
 	if res.Err() != nil {
 		return o, repo.ErrRepoOp{
 			Op:   "updating-order",
@@ -267,6 +631,7 @@ func (r Repo) update(id string, update []bson.D) (Order, error) {
 		}
 	}
 
+	r.audit.Updated(uid, before, o)
 	return o, nil
 }
 
@@ -281,12 +646,62 @@ func (r Repo) Delete(id string) error {
 		}
 	}
 
-	if _, err := r.col.DeleteOne(r.ctx, bson.M{"_id": uid}); err != nil {
+	var before Order
+	if err := mongorepo.SoftDelete(r.ctx, r.col, uid).Decode(&before); err != nil {
 		return repo.ErrRepoOp{
 			Op:   "deleting-order",
-			Code: http.StatusInternalServerError,
+			Code: http.StatusNotFound,
 			Err:  fmt.Errorf("error occured during deleting order. got=%w", err),
 		}
 	}
+
+	r.audit.Deleted(uid, before)
 	return nil
 }
+
+// Restore order soft deleted by id
+func (r Repo) Restore(id string) (Order, error) {
+	var o Order
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return o, repo.ErrRepoOp{
+			Op:   "parsing-order-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	var before Order
+	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&before); err != nil {
+		return o, repo.ErrRepoOp{
+			Op:   "restoring-order",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving order. got=%w", err),
+		}
+	}
+
+	if err := mongorepo.Restore(r.ctx, r.col, uid).Decode(&o); err != nil {
+		return o, repo.ErrRepoOp{
+			Op:   "restoring-order",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("only deleted orders can be restored. got=%w", err),
+		}
+	}
+
+	r.audit.Updated(uid, before, o)
+	return o, nil
+}
+
+// Purge hard delete orders soft deleted before the given time
+func (r Repo) Purge(before time.Time) (int64, error) {
+	n, err := mongorepo.Purge(r.ctx, r.col, before)
+	if err != nil {
+		return n, repo.ErrRepoOp{
+			Op:   "purging-orders",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during purging orders. got=%w", err),
+		}
+	}
+	return n, nil
+}
@@ -3,6 +3,7 @@ package order
 import (
 	"time"
 
+	"github.com/valensto/api_apbp/infra/repo/product"
 	"github.com/valensto/api_apbp/infra/repo/user"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
@@ -14,18 +15,25 @@ type Order struct {
 	ID            primitive.ObjectID `bson:"_id,omitempty"`
 	CreatedAt     time.Time          `bson:"created_at"`
 	ModifiedAt    time.Time          `bson:"modified_at"`
+	DeletedAt     *time.Time         `bson:"deleted_at,omitempty"`
+	Version       int                `bson:"version,omitempty"`
 	Ref           string             `bson:"ref"`
 	RecoveryAt    time.Time          `bson:"recovery_at"`
 	RelationShip  RelationShip       `bson:"relationShip"`
 	ProductsLines []ProductLine      `bson:"products"`
 	Status        string             `bson:"status"`
+	StatusHistory []StatusChange     `bson:"status_history,omitempty"`
+	Totals        *Totals            `bson:"totals,omitempty"`
+	Events        []string           `bson:"events,omitempty"`
+	Score         float64            `bson:"score,omitempty"`
 }
 
 // RelationShip structure representation
 type RelationShip struct {
-	Customer primitive.ObjectID `bson:"customer"`
-	Editor   primitive.ObjectID `bson:"editor"`
-	Included *Included          `bson:"included,omitempty"`
+	Customer     primitive.ObjectID  `bson:"customer"`
+	Editor       primitive.ObjectID  `bson:"editor"`
+	Subscription *primitive.ObjectID `bson:"subscription,omitempty"`
+	Included     *Included           `bson:"included,omitempty"`
 }
 
 // Included structure representation
@@ -36,13 +44,101 @@ type Included struct {
 
 // ProductLine structure representation
 type ProductLine struct {
-	Quantity float32 `bson:"quantity"`
-	Unit     string  `bson:"unit"`
+	Quantity     float32         `bson:"quantity"`
+	Unit         string          `bson:"unit"`
+	Ref          string          `bson:"ref"`
+	Name         string          `bson:"name"`
+	AUW          float32         `bson:"auw"`
+	Price        *product.Price  `bson:"price,omitempty"`
+	Option       *product.Option `bson:"option,omitempty"`
+	Components   []Component     `bson:"components,omitempty"`
+	ActualWeight *float32        `bson:"actual_weight,omitempty"`
+}
+
+// Component is a copy of a bundle component as it was when ordered, quantity is per bundle piece
+type Component struct {
 	Ref      string  `bson:"ref"`
 	Name     string  `bson:"name"`
+	Quantity float32 `bson:"quantity"`
+	Unit     string  `bson:"unit"`
 	AUW      float32 `bson:"auw"`
 }
 
+// Pieces return the number of pieces ordered, grams are converted with the average unit weight
+func (pl ProductLine) Pieces() float64 {
+	if pl.Unit == "p" {
+		return float64(pl.Quantity)
+	}
+	if pl.AUW <= 0 {
+		return 0
+	}
+	return float64(pl.Quantity) / float64(pl.AUW)
+}
+
+// Expand return the lines of the components of a bundle line scaled to the pieces ordered,
+// other lines are returned as is
+func (pl ProductLine) Expand() []ProductLine {
+	if len(pl.Components) == 0 {
+		return []ProductLine{pl}
+	}
+
+	pieces := pl.Pieces()
+	lines := make([]ProductLine, len(pl.Components))
+	for i, c := range pl.Components {
+		lines[i] = ProductLine{
+			Quantity: c.Quantity * float32(pieces),
+			Unit:     c.Unit,
+			Ref:      c.Ref,
+			Name:     c.Name,
+			AUW:      c.AUW,
+		}
+	}
+	return lines
+}
+
+// Raw convert prepared grams of a weighed line back to the raw weight they are cut from, using
+// the option yield. Piece lines are already raw as they count whole pieces of average unit weight
+func (pl ProductLine) Raw(grams float64) float64 {
+	if pl.Unit != "gr" || pl.Option == nil || pl.Option.Yield <= 0 {
+		return grams
+	}
+	return grams * 100 / pl.Option.Yield
+}
+
+// Grams return requested line quantity in grams, pieces are converted with the average unit weight
+func (pl ProductLine) Grams() float64 {
+	if pl.Unit == "gr" {
+		return float64(pl.Quantity)
+	}
+	return float64(pl.Quantity) * float64(pl.AUW)
+}
+
+// Weight return weighed line quantity in grams or the requested one if line isn't weighed yet
+func (pl ProductLine) Weight() float64 {
+	if pl.ActualWeight != nil {
+		return float64(*pl.ActualWeight)
+	}
+	return pl.Grams()
+}
+
+// Variance return the difference in grams between weighed and requested quantity
+func (pl ProductLine) Variance() *float64 {
+	if pl.ActualWeight == nil {
+		return nil
+	}
+	v := float64(*pl.ActualWeight) - pl.Grams()
+	return &v
+}
+
+// Grams return the requested weight of the whole order in grams
+func (o Order) Grams() float64 {
+	var g float64
+	for _, pl := range o.ProductsLines {
+		g += pl.Grams()
+	}
+	return g
+}
+
 type ForecastProduct struct {
 	Name string `bson:"name"`
 	Ref  string `bson:"ref"`
@@ -51,6 +147,30 @@ type ForecastProduct struct {
 type Forecast struct {
 	Product  ForecastProduct `bson:"_id"`
 	Quantity int             `bson:"quantity"`
+	Net      int             `bson:"net"`
+	Grams    float64         `bson:"grams"`
+	Pieces   float64         `bson:"pieces"`
+}
+
+// Forecast buckets
+const (
+	GroupByDay      = "day"
+	GroupByWeek     = "week"
+	GroupByCategory = "category"
+	GroupByStatus   = "status"
+)
+
+// GroupBys lists every forecast bucket
+var GroupBys = []string{GroupByDay, GroupByWeek, GroupByCategory, GroupByStatus}
+
+// ForecastBucket is the forecast of a day, week, category or status
+type ForecastBucket struct {
+	Key      string     `bson:"_id"`
+	Quantity int        `bson:"quantity"`
+	Net      int        `bson:"net"`
+	Grams    float64    `bson:"grams"`
+	Pieces   float64    `bson:"pieces"`
+	Products []Forecast `bson:"products"`
 }
 
 // ODB represents order repository interface
@@ -58,10 +178,22 @@ type ODB interface {
 	Migrate() error
 
 	Forecast(f filter.Query, confirm bool) ([]Forecast, error)
+	ForecastCommitted(f filter.Query) ([]Forecast, error)
+	Preparation(rg filter.Range) ([]Order, error)
+	ForecastSeries(f filter.Query, confirm bool, groupBy string) ([]ForecastBucket, error)
+	Pickups(rg filter.Range, exclude primitive.ObjectID) ([]Order, error)
+	Generated(sub primitive.ObjectID, at time.Time) (bool, error)
 	Read(id string, populate bool) (Order, error)
+	ReadByRef(ref string, populate bool) (Order, error)
 	Delete(id string) error
-	List(f filter.Query) (pagination.Meta, []Order, error)
+	Restore(id string) (Order, error)
+	Purge(before time.Time) (int64, error)
+	List(f filter.Query, customer primitive.ObjectID) (pagination.Meta, []Order, error)
 	Create(s Order) error
-	UpdateFields(id string, upd interface{}) (Order, error)
-	UpdateField(id, field string, v interface{}) (Order, error)
+	UpdateFields(id string, upd interface{}, version int) (Order, error)
+	UpdateField(id, field string, v interface{}, version int) (Order, error)
+	UpdateStatus(id, status string, editor primitive.ObjectID, version int, events ...string) (Order, error)
+	PendingEvents(limit int64) ([]Order, error)
+	ClearEvent(id primitive.ObjectID, kind string) error
+	UpdateLineWeight(id string, index int, weight float32, version int) (Order, error)
 }
@@ -0,0 +1,150 @@
+package order_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/infra/repo/product"
+)
+
+func TestRaw(t *testing.T) {
+	fillet := &product.Option{Name: "filet", Yield: 40}
+
+	var tests = []struct {
+		name     string
+		line     order.ProductLine
+		expected float64
+	}{
+		{"grams without option", order.ProductLine{Quantity: 400, Unit: "gr", AUW: 800}, 400},
+		{"grams with option", order.ProductLine{Quantity: 400, Unit: "gr", AUW: 800, Option: fillet}, 1000},
+		{"pieces without option", order.ProductLine{Quantity: 2, Unit: "p", AUW: 800}, 1600},
+		{"pieces with option", order.ProductLine{Quantity: 2, Unit: "p", AUW: 800, Option: fillet}, 1600},
+		{"option without yield", order.ProductLine{Quantity: 400, Unit: "gr", Option: &product.Option{Name: "nature"}}, 400},
+	}
+
+	for _, tt := range tests {
+		if raw := tt.line.Raw(tt.line.Grams()); raw != tt.expected {
+			t.Errorf("Raw on %v, expected: %v, got: %v", tt.name, tt.expected, raw)
+		}
+	}
+}
+
+func TestEditable(t *testing.T) {
+	var tests = []struct {
+		status   string
+		staff    bool
+		editable bool
+	}{
+		{order.StatusWaiting, false, true},
+		{order.StatusConfirm, false, false},
+		{order.StatusReady, false, false},
+		{order.StatusDelivered, false, false},
+		{order.StatusCancelled, false, false},
+		{order.StatusWaiting, true, true},
+		{order.StatusConfirm, true, true},
+		{order.StatusReady, true, true},
+		{order.StatusDelivered, true, false},
+		{order.StatusCancelled, true, false},
+	}
+
+	for _, tt := range tests {
+		if err := order.Editable(tt.status, tt.staff); (err == nil) != tt.editable {
+			t.Errorf("Editable %v by staff %v, expected editable: %v, got: %v", tt.status, tt.staff, tt.editable, err)
+		}
+	}
+}
+
+func TestAmount(t *testing.T) {
+	weighed := float32(450)
+
+	var tests = []struct {
+		name     string
+		line     order.ProductLine
+		expected int64
+	}{
+		{"grams per kg", order.ProductLine{Quantity: 400, Unit: "gr", Price: &product.Price{PerKg: 2000}}, 800},
+		{"weighed grams per kg", order.ProductLine{Quantity: 400, Unit: "gr", Price: &product.Price{PerKg: 2000}, ActualWeight: &weighed}, 900},
+		{"pieces per piece", order.ProductLine{Quantity: 3, Unit: "p", AUW: 300, Price: &product.Price{PerKg: 2000, PerPiece: 250}}, 750},
+		{"pieces per kg", order.ProductLine{Quantity: 2, Unit: "p", AUW: 300, Price: &product.Price{PerKg: 2000}}, 1200},
+		{"grams per piece", order.ProductLine{Quantity: 500, Unit: "gr", AUW: 200, Price: &product.Price{PerPiece: 250}}, 625},
+		{"grams per piece without auw", order.ProductLine{Quantity: 500, Unit: "gr", Price: &product.Price{PerPiece: 250}}, 0},
+		{"unpriced", order.ProductLine{Quantity: 500, Unit: "gr"}, 0},
+	}
+
+	for _, tt := range tests {
+		if amount := tt.line.Amount(); amount != tt.expected {
+			t.Errorf("Amount on %v, expected: %v, got: %v", tt.name, tt.expected, amount)
+		}
+	}
+}
+
+func TestComputeTotals(t *testing.T) {
+	reduced := &product.Price{PerKg: 1000, PerPiece: 250, Currency: "EUR", VATRate: 5.5}
+	standard := &product.Price{PerKg: 1000, Currency: "EUR", VATRate: 20}
+
+	var tests = []struct {
+		name     string
+		lines    []order.ProductLine
+		expected order.Totals
+	}{
+		{
+			"vat rounded once per rate",
+			[]order.ProductLine{
+				{Quantity: 333, Unit: "gr", Price: reduced},
+				{Quantity: 333, Unit: "gr", Price: reduced},
+			},
+			order.Totals{
+				Currency: "EUR",
+				Subtotal: 666,
+				VAT:      []order.VATLine{{Rate: 5.5, Base: 666, Amount: 37}},
+				Total:    703,
+			},
+		},
+		{
+			"mixed rates",
+			[]order.ProductLine{
+				{Quantity: 1000, Unit: "gr", Price: standard},
+				{Quantity: 3, Unit: "p", Price: reduced},
+			},
+			order.Totals{
+				Currency: "EUR",
+				Subtotal: 1750,
+				VAT: []order.VATLine{
+					{Rate: 5.5, Base: 750, Amount: 41},
+					{Rate: 20, Base: 1000, Amount: 200},
+				},
+				Total: 1991,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if totals := order.ComputeTotals(tt.lines); !reflect.DeepEqual(totals, tt.expected) {
+			t.Errorf("ComputeTotals on %v, expected: %+v, got: %+v", tt.name, tt.expected, totals)
+		}
+	}
+}
+
+func TestCheckPrices(t *testing.T) {
+	eur := &product.Price{PerKg: 1000, Currency: "EUR", VATRate: 5.5}
+	chf := &product.Price{PerKg: 1000, Currency: "CHF", VATRate: 2.5}
+
+	var tests = []struct {
+		name  string
+		lines []order.ProductLine
+		valid bool
+	}{
+		{"single currency", []order.ProductLine{{Unit: "gr", Price: eur}, {Unit: "p", Price: eur}}, true},
+		{"mixed currencies", []order.ProductLine{{Unit: "gr", Price: eur}, {Unit: "gr", Price: chf}}, false},
+		{"unpriced line", []order.ProductLine{{Unit: "gr", Price: eur}, {Unit: "gr"}}, false},
+		{"grams per piece without auw", []order.ProductLine{{Unit: "gr", Price: &product.Price{PerPiece: 250, Currency: "EUR"}}}, false},
+		{"grams per piece with auw", []order.ProductLine{{Unit: "gr", AUW: 200, Price: &product.Price{PerPiece: 250, Currency: "EUR"}}}, true},
+	}
+
+	for _, tt := range tests {
+		if err := order.CheckPrices(tt.lines); (err == nil) != tt.valid {
+			t.Errorf("CheckPrices on %v, expected valid: %v, got: %v", tt.name, tt.valid, err)
+		}
+	}
+}
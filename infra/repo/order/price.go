@@ -0,0 +1,116 @@
+package order
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/valensto/api_apbp/infra/repo"
+)
+
+// Totals structure representation, amounts are in cents
+type Totals struct {
+	Currency string    `bson:"currency"`
+	Subtotal int64     `bson:"subtotal"`
+	VAT      []VATLine `bson:"vat"`
+	Total    int64     `bson:"total"`
+}
+
+// VATLine structure representation of the VAT owed for one rate
+type VATLine struct {
+	Rate   float64 `bson:"rate"`
+	Base   int64   `bson:"base"`
+	Amount int64   `bson:"amount"`
+}
+
+// Amount return line price in cents excluding VAT.
+// Lines in pieces use the piece price when there is one, otherwise weight is charged per kilo,
+// using the weighed quantity once the line is prepared.
+func (pl ProductLine) Amount() int64 {
+	p := pl.Price
+	if p == nil {
+		return 0
+	}
+
+	switch {
+	case pl.Unit == "p" && p.PerPiece > 0:
+		return int64(math.Round(float64(pl.Quantity) * float64(p.PerPiece)))
+	case p.PerKg > 0:
+		return int64(math.Round(pl.Weight() / 1000 * float64(p.PerKg)))
+	case p.PerPiece > 0 && pl.AUW > 0:
+		return int64(math.Round(pl.Weight() / float64(pl.AUW) * float64(p.PerPiece)))
+	}
+	return 0
+}
+
+// Priced reports whether the line amount can be computed from its price
+func (pl ProductLine) Priced() bool {
+	p := pl.Price
+	if p == nil {
+		return false
+	}
+	return p.PerKg > 0 || (p.PerPiece > 0 && (pl.Unit == "p" || pl.AUW > 0))
+}
+
+// CheckPrices return an error unless every line is priced in a single currency,
+// totals would otherwise leave lines out or add amounts of different currencies
+func CheckPrices(lines []ProductLine) error {
+	currency := ""
+	for _, pl := range lines {
+		if !pl.Priced() {
+			return repo.ErrRepoOp{
+				Op:   "checking-prices",
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("product %v has no price for unit %v", pl.Ref, pl.Unit),
+			}
+		}
+		if currency == "" {
+			currency = pl.Price.Currency
+		}
+		if pl.Price.Currency != currency {
+			return repo.ErrRepoOp{
+				Op:   "checking-prices",
+				Code: http.StatusBadRequest,
+				Err:  fmt.Errorf("product %v is priced in %v, other lines in %v", pl.Ref, pl.Price.Currency, currency),
+			}
+		}
+	}
+	return nil
+}
+
+// ComputeTotals return lines subtotal, VAT breakdown by rate and total
+func ComputeTotals(lines []ProductLine) Totals {
+	t := Totals{}
+	bases := make(map[float64]int64)
+
+	for _, pl := range lines {
+		if pl.Price == nil {
+			continue
+		}
+		if t.Currency == "" {
+			t.Currency = pl.Price.Currency
+		}
+
+		a := pl.Amount()
+		t.Subtotal += a
+		bases[pl.Price.VATRate] += a
+	}
+
+	t.VAT = make([]VATLine, 0, len(bases))
+	for rate, base := range bases {
+		t.VAT = append(t.VAT, VATLine{
+			Rate:   rate,
+			Base:   base,
+			Amount: int64(math.Round(float64(base) * rate / 100)),
+		})
+	}
+	sort.Slice(t.VAT, func(i, j int) bool { return t.VAT[i].Rate < t.VAT[j].Rate })
+
+	t.Total = t.Subtotal
+	for _, v := range t.VAT {
+		t.Total += v.Amount
+	}
+
+	return t
+}
@@ -0,0 +1,86 @@
+package order
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Order status values
+const (
+	StatusWaiting   = "waiting"
+	StatusConfirm   = "confirm"
+	StatusReady     = "ready"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+)
+
+// Statuses lists every known order status
+var Statuses = []string{StatusWaiting, StatusConfirm, StatusReady, StatusDelivered, StatusCancelled}
+
+// transitions maps a status to the statuses an order can move to from it.
+// delivered and cancelled are terminal.
+var transitions = map[string][]string{
+	StatusWaiting: {StatusConfirm, StatusCancelled},
+	StatusConfirm: {StatusReady, StatusCancelled},
+	StatusReady:   {StatusDelivered, StatusCancelled},
+}
+
+// StatusChange structure representation
+type StatusChange struct {
+	From   string             `bson:"from"`
+	To     string             `bson:"to"`
+	Editor primitive.ObjectID `bson:"editor"`
+	At     time.Time          `bson:"at"`
+}
+
+// CanTransition reports whether an order can move from one status to another
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition return the status change from one status to another or an error if it's not allowed
+func Transition(from, to string, editor primitive.ObjectID) (StatusChange, error) {
+	if !CanTransition(from, to) {
+		return StatusChange{}, repo.ErrRepoOp{
+			Op:   "updating-order-status",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("order status cannot change from %v to %v", from, to),
+		}
+	}
+
+	return StatusChange{
+		From:   from,
+		To:     to,
+		Editor: editor,
+		At:     time.Now(),
+	}, nil
+}
+
+// Editable return an error if an order in status can no longer be edited, customers only edit
+// waiting orders and staff any order neither delivered nor cancelled
+func Editable(status string, staff bool) error {
+	switch {
+	case status == StatusDelivered || status == StatusCancelled:
+		return repo.ErrRepoOp{
+			Op:   "updating-order",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("%v orders cannot be edited", status),
+		}
+	case !staff && status != StatusWaiting:
+		return repo.ErrRepoOp{
+			Op:   "updating-order",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("customers can only edit waiting orders. got=%v", status),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package outbox
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// schema lists fields events can be filtered and sorted by
+var schema = filter.Schema{
+	"kind":            {Kind: filter.String},
+	"status":          {Kind: filter.String},
+	"resource_id":     {Kind: filter.ID},
+	"attempts":        {Kind: filter.Number, Sortable: true},
+	"created_at":      {Kind: filter.Date, Sortable: true},
+	"next_attempt_at": {Kind: filter.Date, Sortable: true},
+}
+
+func listPipe(f filter.Query) (mongo.Pipeline, error) {
+	var pipeline mongo.Pipeline
+
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return mongorepo.PagePipeline(pipeline, f, schema, bson.D{primitive.E{Key: "created_at", Value: -1}})
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"kind", "resource", "resource_id", "status", "attempts", "created_at", "next_attempt_at"},
+	"properties": bson.M{
+		"kind": bson.M{
+			"enum":        Kinds,
+			"description": "must be a valid kind and is required",
+		},
+		"resource": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"resource_id": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"status": bson.M{
+			"enum":        Statuses,
+			"description": "must be a valid status and is required",
+		},
+		"attempts": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int and is required",
+		},
+		"last_error": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string",
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"next_attempt_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"sent_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+// Migrate create outbox collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, collection, bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.col.Indexes().CreateMany(r.ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				primitive.E{Key: "status", Value: 1},
+				primitive.E{Key: "next_attempt_at", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				primitive.E{Key: "resource_id", Value: 1},
+				primitive.E{Key: "created_at", Value: -1},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
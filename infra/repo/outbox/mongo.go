@@ -0,0 +1,166 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collection = "outbox"
+
+// Repo is a representation of outbox repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new outbox repository
+func NewRepo(ctx context.Context, db *mongo.Database) OB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection(collection)
+	return r
+}
+
+// Enqueue store a new event for the dispatcher unless the resource already has one of
+// the same kind, relaying an event twice after a crash then leaves a single delivery
+func (r Repo) Enqueue(e Event) error {
+	filter := bson.M{"kind": e.Kind, "resource_id": e.ResourceID}
+	opts := options.Update().SetUpsert(true)
+
+	if _, err := r.col.UpdateOne(r.ctx, filter, bson.M{"$setOnInsert": e}, opts); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "enqueuing-event",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during enqueuing %v event. got=%w", e.Kind, err),
+		}
+	}
+	return nil
+}
+
+// Claim take the oldest due pending event and hide it from other dispatchers for the lease,
+// claimed reports whether an event was due. The attempt is counted on claim so that
+// a dispatcher crashing mid delivery still moves the event towards the dead letter.
+func (r Repo) Claim(now time.Time, lease time.Duration) (Event, bool, error) {
+	var e Event
+
+	filter := bson.M{
+		"status":          StatusPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set": bson.M{"next_attempt_at": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1})
+	after := options.After
+	opts.ReturnDocument = &after
+
+	err := r.col.FindOneAndUpdate(r.ctx, filter, update, opts).Decode(&e)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return e, false, nil
+	}
+	if err != nil {
+		return e, false, repo.ErrRepoOp{
+			Op:   "claiming-event",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during claiming event. got=%w", err),
+		}
+	}
+
+	return e, true, nil
+}
+
+// Sent mark an event delivered
+func (r Repo) Sent(id primitive.ObjectID) error {
+	return r.set(id, bson.M{"status": StatusSent, "sent_at": time.Now()})
+}
+
+// Retry record a failed delivery and schedule the next attempt
+func (r Repo) Retry(id primitive.ObjectID, reason string, at time.Time) error {
+	return r.set(id, bson.M{"last_error": reason, "next_attempt_at": at})
+}
+
+// Dead record a failed delivery and stop retrying the event
+func (r Repo) Dead(id primitive.ObjectID, reason string) error {
+	return r.set(id, bson.M{"status": StatusDead, "last_error": reason})
+}
+
+func (r Repo) set(id primitive.ObjectID, fields bson.M) error {
+	if _, err := r.col.UpdateOne(r.ctx, bson.M{"_id": id}, bson.M{"$set": fields}); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "updating-event",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during updating event %v. got=%w", id.Hex(), err),
+		}
+	}
+	return nil
+}
+
+// List return a list of events
+func (r Repo) List(f filter.Query) (pagination.Meta, []Event, error) {
+	res := struct {
+		Events []Event                  `bson:"data"`
+		Meta   []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := listPipe(f)
+	if err != nil {
+		return meta, res.Events, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Events, repo.ErrRepoOp{
+			Op:   "outbox-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during outbox aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Events, repo.ErrRepoOp{
+			Op:   "retrieving-events",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving events. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Events, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Events, repo.ErrRepoOp{
+			Op:   "retrieving-events",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Events, nil
+}
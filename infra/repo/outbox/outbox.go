@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of notification events
+const (
+	KindOrderCreated = "order.created"
+	KindOrderReady   = "order.ready"
+)
+
+// Kinds list valid notification events
+var Kinds = []string{KindOrderCreated, KindOrderReady}
+
+// Delivery statuses of an event, dead events exhausted their attempts
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusDead    = "dead"
+)
+
+// Statuses list valid delivery statuses
+var Statuses = []string{StatusPending, StatusSent, StatusDead}
+
+// Event structure representation of a notification waiting to be delivered
+type Event struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Kind          string             `bson:"kind"`
+	Resource      string             `bson:"resource"`
+	ResourceID    primitive.ObjectID `bson:"resource_id"`
+	Status        string             `bson:"status"`
+	Attempts      int                `bson:"attempts"`
+	LastError     string             `bson:"last_error,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at"`
+	SentAt        *time.Time         `bson:"sent_at,omitempty"`
+}
+
+// NewEvent return a pending event about a resource, due now
+func NewEvent(kind, resource string, id primitive.ObjectID) Event {
+	now := time.Now()
+	return Event{
+		ID:            primitive.NewObjectID(),
+		Kind:          kind,
+		Resource:      resource,
+		ResourceID:    id,
+		Status:        StatusPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+}
+
+// OB represents outbox repository interface
+type OB interface {
+	Migrate() error
+
+	Enqueue(e Event) error
+	Claim(now time.Time, lease time.Duration) (Event, bool, error)
+	Sent(id primitive.ObjectID) error
+	Retry(id primitive.ObjectID, reason string, at time.Time) error
+	Dead(id primitive.ObjectID, reason string) error
+	List(f filter.Query) (pagination.Meta, []Event, error)
+}
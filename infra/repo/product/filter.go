@@ -8,85 +8,59 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func listPipe(f filter.Query) mongo.Pipeline {
-	var pipeline mongo.Pipeline
-
-	pipeline = searchTerm(pipeline, f.Term)
-	pipeline = mongorepo.PaginatePipeline(pipeline, f.Pagination)
-
-	return pipeline
+// schema lists fields products can be filtered and sorted by
+var schema = filter.Schema{
+	"ref":             {Kind: filter.String, Sortable: true},
+	"name":            {Kind: filter.String, Sortable: true},
+	"category_id":     {Kind: filter.ID},
+	"auw":             {Kind: filter.Number, Sortable: true},
+	"price.per_kg":    {Kind: filter.Number, Sortable: true},
+	"price.per_piece": {Kind: filter.Number, Sortable: true},
+	"created_at":      {Kind: filter.Date, Sortable: true},
+	"modified_at":     {Kind: filter.Date, Sortable: true},
 }
 
-func categoryPipe(f filter.Query) mongo.Pipeline {
+func listPipe(f filter.Query) (mongo.Pipeline, error) {
 	var pipeline mongo.Pipeline
 
-	groupStage := bson.D{primitive.E{
-		Key: "$group",
-		Value: bson.D{
-			primitive.E{
-				Key: "_id",
-				Value: bson.D{primitive.E{
-					Key:   "name",
-					Value: "$category",
-				}},
-			},
-			primitive.E{
-				Key: "products",
-				Value: bson.D{primitive.E{
-					Key:   "$addToSet",
-					Value: "$$ROOT",
-				}},
-			},
-		},
-	}}
-
-	pipeline = append(pipeline, groupStage)
+	pipeline = mongorepo.TextPipeline(pipeline, f.Term)
+	pipeline = mongorepo.AlivePipeline(pipeline, f.WithDeleted)
 
-	sortStage := bson.D{primitive.E{
-		Key: "$sort",
-		Value: bson.D{primitive.E{
-			Key:   "name",
-			Value: 1,
-		}},
-	}}
-
-	pipeline = append(pipeline, sortStage)
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
 
-	return pipeline
+	return mongorepo.PagePipeline(pipeline, f, schema, mongorepo.TextSort(f.Term, nil))
 }
 
-func searchTerm(pipeline mongo.Pipeline, str string) mongo.Pipeline {
-	if str != "" {
-		pipeline = append(pipeline, bson.D{primitive.E{
-			Key: "$match",
-			Value: bson.D{primitive.E{
-				Key: "$or",
-				Value: bson.A{
-					bson.D{primitive.E{
-						Key: "name",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-					bson.D{primitive.E{
-						Key: "ref",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-					bson.D{primitive.E{
-						Key: "category",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-				},
-			}},
-		}})
-	}
+func catalogPipe(f filter.Query) mongo.Pipeline {
+	pipeline := mongorepo.AlivePipeline(nil, false)
 
-	return pipeline
+	return append(pipeline,
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "name", Value: 1}}}},
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: "$category_id"},
+			primitive.E{Key: "products", Value: bson.M{"$push": "$$ROOT"}},
+		}}},
+		bson.D{primitive.E{Key: "$lookup", Value: bson.D{
+			primitive.E{Key: "from", Value: "categories"},
+			primitive.E{Key: "localField", Value: "_id"},
+			primitive.E{Key: "foreignField", Value: "_id"},
+			primitive.E{Key: "as", Value: "category"},
+		}}},
+		bson.D{primitive.E{Key: "$unwind", Value: bson.D{
+			primitive.E{Key: "path", Value: "$category"},
+			primitive.E{Key: "preserveNullAndEmptyArrays", Value: true},
+		}}},
+		bson.D{primitive.E{Key: "$match", Value: bson.M{"category.visible": bson.M{"$ne": false}}}},
+		bson.D{primitive.E{Key: "$addFields", Value: bson.M{
+			"uncategorised": bson.M{"$eq": bson.A{bson.M{"$type": "$category"}, "missing"}},
+		}}},
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{
+			primitive.E{Key: "uncategorised", Value: 1},
+			primitive.E{Key: "category.position", Value: 1},
+			primitive.E{Key: "category.name", Value: 1},
+		}}},
+	)
 }
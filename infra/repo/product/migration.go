@@ -1,7 +1,9 @@
 package product
 
 import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -18,9 +20,9 @@ var jsonSchema = bson.M{
 			"bsonType":    "string",
 			"description": "must be a string and is required",
 		},
-		"category": bson.M{
-			"bsonType":    "string",
-			"description": "must be a string",
+		"category_id": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be an objectId",
 		},
 		"description": bson.M{
 			"bsonType":    "string",
@@ -30,6 +32,39 @@ var jsonSchema = bson.M{
 			"bsonType":    "number",
 			"description": "must be a number and is required",
 		},
+		"auw_samples": bson.M{
+			"bsonType":    "int",
+			"description": "must be an int",
+		},
+		"price": priceSchema,
+		"options": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array",
+			"items":       OptionSchema,
+		},
+		"components": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array",
+			"items": bson.M{
+				"bsonType":    "object",
+				"description": "must be an object",
+				"required":    []string{"ref", "quantity", "unit"},
+				"properties": bson.M{
+					"ref": bson.M{
+						"bsonType":    "string",
+						"description": "must be a string and is required",
+					},
+					"quantity": bson.M{
+						"bsonType":    "double",
+						"description": "must be a double and is required",
+					},
+					"unit": bson.M{
+						"enum":        []string{"gr", "p"},
+						"description": "must be a string and is required",
+					},
+				},
+			},
+		},
 		"created_at": bson.M{
 			"bsonType":    "date",
 			"description": "must be a date",
@@ -38,6 +73,64 @@ var jsonSchema = bson.M{
 			"bsonType":    "date",
 			"description": "must be a date",
 		},
+		"deleted_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"version": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int",
+		},
+	},
+}
+
+// OptionSchema validates a preparation option, it is shared with the order lines copying it
+var OptionSchema = bson.M{
+	"bsonType":    "object",
+	"description": "must be an object",
+	"required":    []string{"name", "yield"},
+	"properties": bson.M{
+		"name": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"yield": bson.M{
+			"bsonType":         "double",
+			"exclusiveMinimum": true,
+			"minimum":          0,
+			"maximum":          100,
+			"description":      "must be a percentage above 0 and is required",
+		},
+		"prep_minutes": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int",
+		},
+	},
+}
+
+var priceSchema = bson.M{
+	"bsonType":    "object",
+	"description": "must be an object",
+	"required":    []string{"currency", "vat_rate"},
+	"properties": bson.M{
+		"per_kg": bson.M{
+			"bsonType":    "long",
+			"description": "must be a long",
+		},
+		"per_piece": bson.M{
+			"bsonType":    "long",
+			"description": "must be a long",
+		},
+		"currency": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"vat_rate": bson.M{
+			"bsonType":    "double",
+			"description": "must be a double and is required",
+		},
 	},
 }
 
@@ -47,8 +140,7 @@ var validator = bson.M{
 
 // Migrate create product collection with schema and indexs
 func (r *Repo) Migrate() error {
-	opts := options.CreateCollection().SetValidator(validator)
-	if err := r.db.CreateCollection(r.ctx, "products", opts); err != nil {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "products", validator); err != nil {
 		return err
 	}
 
@@ -60,5 +152,21 @@ func (r *Repo) Migrate() error {
 		return err
 	}
 
+	_, err = r.db.Collection("products").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys: bson.M{"category_id": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("products").Indexes().CreateOne(r.ctx, mongorepo.TextIndex(bson.D{
+		primitive.E{Key: "name", Value: 10},
+		primitive.E{Key: "ref", Value: 8},
+		primitive.E{Key: "description", Value: 1},
+	}))
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -2,12 +2,15 @@ package product
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,9 +21,10 @@ import (
 
 // Repo is a representation of product repository structure
 type Repo struct {
-	db  *mongo.Database
-	ctx context.Context
-	col *mongo.Collection
+	db    *mongo.Database
+	ctx   context.Context
+	col   *mongo.Collection
+	audit audit.Recorder
 }
 
 // NewRepo return a new product repository
@@ -30,6 +34,7 @@ func NewRepo(ctx context.Context, db *mongo.Database) PDB {
 		ctx: ctx,
 	}
 	r.col = r.db.Collection("products")
+	r.audit = audit.NewRecorder(ctx, db, "products")
 	return r
 }
 
@@ -42,28 +47,80 @@ func (r Repo) List(f filter.Query) (pagination.Meta, []Product, error) {
 	return total, products, nil
 }
 
-// Categories func
-func (r Repo) Categories(f filter.Query) ([]Category, error) {
-	var fs []Category
+// Catalog return visible categories with their products
+func (r Repo) Catalog(f filter.Query) ([]Catalog, error) {
+	var cs []Catalog
 
-	curs, err := r.col.Aggregate(r.ctx, categoryPipe(f))
+	curs, err := r.col.Aggregate(r.ctx, catalogPipe(f))
 	if err != nil {
-		return fs, repo.ErrRepoOp{
-			Op:   "order-aggregation",
+		return cs, repo.ErrRepoOp{
+			Op:   "catalog-aggregation",
 			Code: http.StatusInternalServerError,
-			Err:  fmt.Errorf("error occured during order aggregation. got=%w", err),
+			Err:  fmt.Errorf("error occured during catalog aggregation. got=%w", err),
 		}
 	}
 
-	if err := curs.All(r.ctx, &fs); err != nil {
-		return fs, repo.ErrRepoOp{
-			Op:   "order-aggregation",
+	if err := curs.All(r.ctx, &cs); err != nil {
+		return cs, repo.ErrRepoOp{
+			Op:   "catalog-aggregation",
 			Code: http.StatusInternalServerError,
-			Err:  fmt.Errorf("error occured during order retrieving. got=%w", err),
+			Err:  fmt.Errorf("error occured during catalog retrieving. got=%w", err),
 		}
 	}
 
-	return fs, nil
+	return cs, nil
+}
+
+// InCategory count alive products of the category
+func (r Repo) InCategory(id primitive.ObjectID) (int64, error) {
+	n, err := r.col.CountDocuments(r.ctx, mongorepo.Alive(bson.M{"category_id": id}))
+	if err != nil {
+		return n, repo.ErrRepoOp{
+			Op:   "counting-products",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during counting products. got=%w", err),
+		}
+	}
+	return n, nil
+}
+
+// LegacyCategories return the free text categories products still carry
+func (r Repo) LegacyCategories() ([]string, error) {
+	var names []string
+
+	vs, err := r.col.Distinct(r.ctx, "category", bson.M{"category": bson.M{"$type": "string"}})
+	if err != nil {
+		return names, repo.ErrRepoOp{
+			Op:   "retrieving-legacy-categories",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving legacy categories. got=%w", err),
+		}
+	}
+
+	for _, v := range vs {
+		if name, ok := v.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// AssignCategory move products of a free text category to the category id
+func (r Repo) AssignCategory(legacy string, id primitive.ObjectID) (int64, error) {
+	update := bson.M{
+		"$set":   bson.M{"category_id": id, "modified_at": time.Now()},
+		"$unset": bson.M{"category": ""},
+	}
+
+	res, err := r.col.UpdateMany(r.ctx, bson.M{"category": legacy}, update)
+	if err != nil {
+		return 0, repo.ErrRepoOp{
+			Op:   "assigning-category",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during assigning category. got=%w", err),
+		}
+	}
+	return res.ModifiedCount, nil
 }
 
 // Read return product by id
@@ -78,7 +135,7 @@ func (r Repo) Read(id string) (Product, error) {
 		}
 	}
 
-	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&product); err != nil {
+	if err := r.col.FindOne(r.ctx, mongorepo.Alive(bson.M{"_id": uid})).Decode(&product); err != nil {
 		return product, repo.ErrRepoOp{
 			Op:   "retrieving-product",
 			Code: http.StatusInternalServerError,
@@ -88,6 +145,19 @@ func (r Repo) Read(id string) (Product, error) {
 	return product, nil
 }
 
+// ReadByRef product from repo
+func (r Repo) ReadByRef(ref string) (Product, error) {
+	product := Product{}
+	if err := r.col.FindOne(r.ctx, mongorepo.Alive(bson.M{"ref": ref})).Decode(&product); err != nil {
+		return product, repo.ErrRepoOp{
+			Op:   "retrieving-product",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("product not found. ref=%v doesn't exist", ref),
+		}
+	}
+	return product, nil
+}
+
 func (r Repo) retrieve(f filter.Query) (pagination.Meta, []Product, error) {
 	resp := struct {
 		Products []Product                `bson:"data"`
@@ -96,7 +166,12 @@ func (r Repo) retrieve(f filter.Query) (pagination.Meta, []Product, error) {
 
 	meta := pagination.Meta{}
 
-	curs, err := r.col.Aggregate(r.ctx, listPipe(f))
+	pipeline, err := listPipe(f)
+	if err != nil {
+		return meta, resp.Products, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
 	if err != nil {
 		return meta, resp.Products, repo.ErrRepoOp{
 			Op:   "product-aggregation",
@@ -137,7 +212,7 @@ func (r Repo) retrieve(f filter.Query) (pagination.Meta, []Product, error) {
 
 // Create product to repo
 func (r Repo) Create(usr Product) error {
-	_, err := r.col.InsertOne(r.ctx, usr)
+	res, err := r.col.InsertOne(r.ctx, usr)
 	if err != nil {
 		return repo.ErrRepoOp{
 			Op:   "create-product",
@@ -145,15 +220,29 @@ func (r Repo) Create(usr Product) error {
 			Err:  fmt.Errorf("error occured during creating. got=%w", err),
 		}
 	}
+
+	uid, _ := res.InsertedID.(primitive.ObjectID)
+	r.audit.Created(uid, usr)
 	return nil
 }
 
-// UpdateFields product from repo
-func (r Repo) UpdateFields(id string, updPct Product) (Product, error) {
+// UpdateFields product from repo, the average unit weight is learned from weighings and kept as is
+func (r Repo) UpdateFields(id string, updPct Product, version int) (Product, error) {
+	fields, err := toM(updPct)
+	if err != nil {
+		return Product{}, repo.ErrRepoOp{
+			Op:   "updating-product",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during encoding product. got=%w", err),
+		}
+	}
+	delete(fields, "auw")
+	delete(fields, "auw_samples")
+
 	update := []bson.D{
 		{primitive.E{
 			Key:   "$set",
-			Value: updPct,
+			Value: fields,
 		}},
 		{primitive.E{
 			Key: "$addFields",
@@ -164,19 +253,58 @@ func (r Repo) UpdateFields(id string, updPct Product) (Product, error) {
 		}},
 	}
 
-	u, err := r.update(id, update)
+	return r.update(id, update, version)
+}
+
+// auwWindow is the number of weighings the average unit weight is smoothed over
+const auwWindow = 20
+
+// RecordUnitWeight fold a weighed unit weight into the product rolling average unit weight
+func (r Repo) RecordUnitWeight(ref string, weight float32) (Product, error) {
+	p, err := r.ReadByRef(ref)
 	if err != nil {
-		return u, repo.ErrRepoOp{
-			Op:   "updating-user",
-			Code: http.StatusBadRequest,
-			Err:  fmt.Errorf("error occured during updating. got=%w", err),
-		}
+		return p, err
 	}
 
-	return u, nil
+	update := []bson.D{
+		{primitive.E{
+			Key: "$set",
+			Value: bson.D{primitive.E{
+				Key: "auw_samples",
+				Value: bson.M{"$min": bson.A{
+					bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$auw_samples", 0}}, 1}},
+					auwWindow,
+				}},
+			}},
+		}},
+		{primitive.E{
+			Key: "$set",
+			Value: bson.D{
+				primitive.E{
+					Key: "auw",
+					Value: bson.M{"$add": bson.A{
+						"$auw",
+						bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{weight, "$auw"}}, "$auw_samples"}},
+					}},
+				},
+				primitive.E{Key: "modified_at", Value: time.Now()},
+			},
+		}},
+	}
+
+	return r.update(p.ID.Hex(), update, repo.AnyVersion)
 }
 
-func (r Repo) update(id string, update []bson.D) (Product, error) {
+func toM(doc interface{}) (bson.M, error) {
+	m := bson.M{}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return m, err
+	}
+	return m, bson.Unmarshal(raw, &m)
+}
+
+func (r Repo) update(id string, update []bson.D, version int) (Product, error) {
 	var p Product
 
 	uid, err := primitive.ObjectIDFromHex(id)
@@ -188,7 +316,23 @@ func (r Repo) update(id string, update []bson.D) (Product, error) {
 		}
 	}
 
-	filter := bson.M{"_id": bson.M{"$eq": uid}}
+	filter := mongorepo.Alive(bson.M{"_id": bson.M{"$eq": uid}})
+
+	var before Product
+	if err := r.col.FindOne(r.ctx, filter).Decode(&before); err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "updating-product",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	if mongorepo.Stale(version, before.Version) {
+		return p, mongorepo.VersionErr("updating-product", version, before.Version)
+	}
+	filter = mongorepo.Expect(filter, version)
+
+	update = append(update, mongorepo.BumpStage())
 
 	opts := options.FindOneAndUpdate()
 	after := options.After
@@ -200,6 +344,9 @@ func (r Repo) update(id string, update []bson.D) (Product, error) {
 		update,
 		opts,
 	)
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) && version != repo.AnyVersion {
+		return p, mongorepo.VersionErr("updating-product", version, before.Version)
+	}
 	if res.Err() != nil {
 		return p, repo.ErrRepoOp{
 			Op:   "updating-product",
@@ -216,6 +363,7 @@ func (r Repo) update(id string, update []bson.D) (Product, error) {
 		}
 	}
 
+	r.audit.Updated(uid, before, p)
 	return p, nil
 }
 
@@ -230,12 +378,62 @@ func (r Repo) Delete(id string) error {
 		}
 	}
 
-	if _, err := r.col.DeleteOne(r.ctx, bson.M{"_id": uid}); err != nil {
+	var before Product
+	if err := mongorepo.SoftDelete(r.ctx, r.col, uid).Decode(&before); err != nil {
 		return repo.ErrRepoOp{
 			Op:   "deleting-product",
-			Code: http.StatusInternalServerError,
+			Code: http.StatusNotFound,
 			Err:  fmt.Errorf("error occured during deleting product. got=%w", err),
 		}
 	}
+
+	r.audit.Deleted(uid, before)
 	return nil
 }
+
+// Restore product soft deleted by id
+func (r Repo) Restore(id string) (Product, error) {
+	var p Product
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "parsing-product-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	var before Product
+	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&before); err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "restoring-product",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving product. got=%w", err),
+		}
+	}
+
+	if err := mongorepo.Restore(r.ctx, r.col, uid).Decode(&p); err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "restoring-product",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("only deleted products can be restored. got=%w", err),
+		}
+	}
+
+	r.audit.Updated(uid, before, p)
+	return p, nil
+}
+
+// Purge hard delete products soft deleted before the given time
+func (r Repo) Purge(before time.Time) (int64, error) {
+	n, err := mongorepo.Purge(r.ctx, r.col, before)
+	if err != nil {
+		return n, repo.ErrRepoOp{
+			Op:   "purging-products",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during purging products. got=%w", err),
+		}
+	}
+	return n, nil
+}
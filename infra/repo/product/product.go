@@ -3,6 +3,7 @@ package product
 import (
 	"time"
 
+	"github.com/valensto/api_apbp/infra/repo/category"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,29 +11,82 @@ import (
 
 // Product structure representation
 type Product struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty"`
-	CreatedAt   time.Time          `bson:"created_at"`
-	ModifiedAt  time.Time          `bson:"modified_at"`
-	Ref         string             `bson:"ref"`
-	Name        string             `bson:"name"`
-	Category    string             `bson:"category,omitempty"`
-	Description string             `bson:"description,omitempty"`
-	AUW         float32            `bson:"auw"`
+	ID          primitive.ObjectID  `bson:"_id,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at"`
+	ModifiedAt  time.Time           `bson:"modified_at"`
+	DeletedAt   *time.Time          `bson:"deleted_at,omitempty"`
+	Version     int                 `bson:"version,omitempty"`
+	Ref         string              `bson:"ref"`
+	Name        string              `bson:"name"`
+	CategoryID  *primitive.ObjectID `bson:"category_id,omitempty"`
+	Description string              `bson:"description,omitempty"`
+	AUW         float32             `bson:"auw"`
+	AUWSamples  int                 `bson:"auw_samples,omitempty"`
+	Price       *Price              `bson:"price,omitempty"`
+	Options     []Option            `bson:"options,omitempty"`
+	Components  []Component         `bson:"components,omitempty"`
+	Score       float64             `bson:"score,omitempty"`
 }
 
-type Category struct {
-	Category map[string]string `bson:"_id"`
-	Products []Product         `bson:"products"`
+// Option is a preparation the product can be ordered with, yield is the percentage
+// of the raw weight left once prepared and prep minutes the extra work per line
+type Option struct {
+	Name        string  `bson:"name"`
+	Yield       float64 `bson:"yield"`
+	PrepMinutes int     `bson:"prep_minutes,omitempty"`
+}
+
+// Component is a product a bundle is made of, quantity is per bundle piece
+type Component struct {
+	Ref      string  `bson:"ref"`
+	Quantity float32 `bson:"quantity"`
+	Unit     string  `bson:"unit"`
+}
+
+// IsBundle reports whether the product is made of other products
+func (p Product) IsBundle() bool {
+	return len(p.Components) > 0
+}
+
+// Option return the preparation option of the product by name
+func (p Product) Option(name string) (Option, bool) {
+	for _, o := range p.Options {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// Price structure representation, amounts are in cents excluding VAT
+type Price struct {
+	PerKg    int64   `bson:"per_kg,omitempty"`
+	PerPiece int64   `bson:"per_piece,omitempty"`
+	Currency string  `bson:"currency"`
+	VATRate  float64 `bson:"vat_rate"`
+}
+
+// Catalog is a visible category with its products, products without category come last with none
+type Catalog struct {
+	Category *category.Category `bson:"category"`
+	Products []Product          `bson:"products"`
 }
 
 // PDB represents product repository interface
 type PDB interface {
 	Migrate() error
 
-	Categories(f filter.Query) ([]Category, error)
+	Catalog(f filter.Query) ([]Catalog, error)
+	InCategory(id primitive.ObjectID) (int64, error)
+	LegacyCategories() ([]string, error)
+	AssignCategory(legacy string, id primitive.ObjectID) (int64, error)
 	Read(id string) (Product, error)
+	ReadByRef(ref string) (Product, error)
 	Delete(id string) error
+	Restore(id string) (Product, error)
+	Purge(before time.Time) (int64, error)
 	List(f filter.Query) (pagination.Meta, []Product, error)
 	Create(s Product) error
-	UpdateFields(id string, updPct Product) (Product, error)
+	UpdateFields(id string, updPct Product, version int) (Product, error)
+	RecordUnitWeight(ref string, weight float32) (Product, error)
 }
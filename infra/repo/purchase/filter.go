@@ -0,0 +1,61 @@
+package purchase
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// schema lists fields purchases can be filtered and sorted by
+var schema = filter.Schema{
+	"status":      {Kind: filter.String, Sortable: true},
+	"supplier":    {Kind: filter.ID},
+	"lines.ref":   {Kind: filter.String},
+	"expected_at": {Kind: filter.Date, Sortable: true},
+	"received_at": {Kind: filter.Date, Sortable: true},
+	"created_at":  {Kind: filter.Date, Sortable: true},
+}
+
+func listPipe(f filter.Query) (mongo.Pipeline, error) {
+	var pipeline mongo.Pipeline
+
+	pipeline = searchTerm(pipeline, f.Term)
+
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return mongorepo.PagePipeline(pipeline, f, schema, bson.D{primitive.E{Key: "created_at", Value: -1}})
+}
+
+func searchTerm(pipeline mongo.Pipeline, str string) mongo.Pipeline {
+	if str != "" {
+		pipeline = append(pipeline, bson.D{primitive.E{
+			Key: "$match",
+			Value: bson.D{primitive.E{
+				Key: "$or",
+				Value: bson.A{
+					bson.D{primitive.E{
+						Key: "status",
+						Value: bson.M{
+							"$regex":   str,
+							"$options": "i",
+						},
+					}},
+					bson.D{primitive.E{
+						Key: "lines.ref",
+						Value: bson.M{
+							"$regex":   str,
+							"$options": "i",
+						},
+					}},
+				},
+			}},
+		}})
+	}
+
+	return pipeline
+}
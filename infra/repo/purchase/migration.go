@@ -0,0 +1,94 @@
+package purchase
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"supplier", "editor", "status", "from", "to", "lines"},
+	"properties": bson.M{
+		"supplier": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"editor": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"status": bson.M{
+			"enum":        Statuses,
+			"description": "must be a purchase status and is required",
+		},
+		"from": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"to": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"expected_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"received_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"lines": bson.M{
+			"bsonType":    "array",
+			"minItems":    1,
+			"description": "must be an array of lines and is required",
+			"items": bson.M{
+				"bsonType": "object",
+				"required": []string{"ref", "name", "quantity"},
+				"properties": bson.M{
+					"ref": bson.M{
+						"bsonType":    "string",
+						"description": "must be a string and is required",
+					},
+					"name": bson.M{
+						"bsonType":    "string",
+						"description": "must be a string and is required",
+					},
+					"quantity": bson.M{
+						"bsonType":    "number",
+						"description": "must be a number and is required",
+					},
+					"received": bson.M{
+						"bsonType":    "number",
+						"description": "must be a number",
+					},
+				},
+			},
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"modified_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+// Migrate create purchases collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "purchases", bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("purchases").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "supplier", Value: 1}, primitive.E{Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package purchase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a representation of purchase repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new purchase repository
+func NewRepo(ctx context.Context, db *mongo.Database) PDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("purchases")
+	return r
+}
+
+// List return a list of purchases, latest first
+func (r Repo) List(f filter.Query) (pagination.Meta, []Purchase, error) {
+	res := struct {
+		Purchases []Purchase               `bson:"data"`
+		Meta      []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := listPipe(f)
+	if err != nil {
+		return meta, res.Purchases, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Purchases, repo.ErrRepoOp{
+			Op:   "purchase-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during purchase aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Purchases, repo.ErrRepoOp{
+			Op:   "retrieving-purchase",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving purchase. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Purchases, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Purchases, repo.ErrRepoOp{
+			Op:   "retrieving-purchase",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Purchases, nil
+}
+
+// Read return purchase by id
+func (r Repo) Read(id string) (Purchase, error) {
+	p := Purchase{}
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "parsing-purchase-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&p); err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "retrieving-purchase",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving purchase. got=%w", err),
+		}
+	}
+	return p, nil
+}
+
+// Create purchase to repo
+func (r Repo) Create(p Purchase) error {
+	_, err := r.col.InsertOne(r.ctx, p)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "create-purchase",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// SaveDraft create the draft purchase of the supplier for the range, or replace the lines
+// of the one already drafted so generating twice doesn't duplicate it
+func (r Repo) SaveDraft(p Purchase) (Purchase, error) {
+	var saved Purchase
+
+	filter := bson.M{
+		"supplier": p.Supplier,
+		"from":     p.From,
+		"to":       p.To,
+		"status":   StatusDraft,
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"editor":      p.Editor,
+			"expected_at": p.ExpectedAt,
+			"lines":       p.Lines,
+			"modified_at": p.ModifiedAt,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        p.ID,
+			"created_at": p.CreatedAt,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true)
+	after := options.After
+	opts.ReturnDocument = &after
+
+	if err := r.col.FindOneAndUpdate(r.ctx, filter, update, opts).Decode(&saved); err != nil {
+		return saved, repo.ErrRepoOp{
+			Op:   "saving-purchase-draft",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during saving draft. got=%w", err),
+		}
+	}
+	return saved, nil
+}
+
+// Send mark a draft purchase as sent to its supplier
+func (r Repo) Send(id string) (Purchase, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"status":      StatusSent,
+			"modified_at": time.Now(),
+		},
+	}
+
+	return r.update(id, StatusDraft, update)
+}
+
+// Receive record received quantities of a sent purchase
+func (r Repo) Receive(id string, lines []Line) (Purchase, error) {
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":      StatusReceived,
+			"lines":       lines,
+			"received_at": now,
+			"modified_at": now,
+		},
+	}
+
+	return r.update(id, StatusSent, update)
+}
+
+// update purchase if it still has the given status
+func (r Repo) update(id, status string, update bson.M) (Purchase, error) {
+	var p Purchase
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "parsing-purchase-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	opts := options.FindOneAndUpdate()
+	after := options.After
+	opts.ReturnDocument = &after
+
+	err = r.col.FindOneAndUpdate(r.ctx, bson.M{"_id": uid, "status": status}, update, opts).Decode(&p)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return p, repo.ErrRepoOp{
+			Op:   "updating-purchase",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("purchase %v is not %v", id, status),
+		}
+	}
+	if err != nil {
+		return p, repo.ErrRepoOp{
+			Op:   "updating-purchase",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	return p, nil
+}
+
+// Delete draft purchase by id
+func (r Repo) Delete(id string) error {
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "parsing-purchase-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	res, err := r.col.DeleteOne(r.ctx, bson.M{"_id": uid, "status": StatusDraft})
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "deleting-purchase",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during deleting purchase. got=%w", err),
+		}
+	}
+	if res.DeletedCount == 0 {
+		return repo.ErrRepoOp{
+			Op:   "deleting-purchase",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("only draft purchases can be deleted"),
+		}
+	}
+	return nil
+}
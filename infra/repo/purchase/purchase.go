@@ -0,0 +1,55 @@
+package purchase
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purchase order status values
+const (
+	StatusDraft    = "draft"
+	StatusSent     = "sent"
+	StatusReceived = "received"
+)
+
+// Statuses lists every known purchase order status
+var Statuses = []string{StatusDraft, StatusSent, StatusReceived}
+
+// Purchase structure representation of an order to a supplier
+type Purchase struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	ModifiedAt time.Time          `bson:"modified_at"`
+	Supplier   primitive.ObjectID `bson:"supplier"`
+	Editor     primitive.ObjectID `bson:"editor"`
+	Status     string             `bson:"status"`
+	From       time.Time          `bson:"from"`
+	To         time.Time          `bson:"to"`
+	ExpectedAt time.Time          `bson:"expected_at"`
+	ReceivedAt *time.Time         `bson:"received_at,omitempty"`
+	Lines      []Line             `bson:"lines"`
+}
+
+// Line structure representation, quantities are in grams
+type Line struct {
+	Ref      string   `bson:"ref"`
+	Name     string   `bson:"name"`
+	Quantity float64  `bson:"quantity"`
+	Received *float64 `bson:"received,omitempty"`
+}
+
+// PDB represents purchase repository interface
+type PDB interface {
+	Migrate() error
+
+	Read(id string) (Purchase, error)
+	Delete(id string) error
+	List(f filter.Query) (pagination.Meta, []Purchase, error)
+	Create(p Purchase) error
+	SaveDraft(p Purchase) (Purchase, error)
+	Send(id string) (Purchase, error)
+	Receive(id string, lines []Line) (Purchase, error)
+}
@@ -0,0 +1,62 @@
+package reset
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"user", "token_hash", "created_at", "expires_at"},
+	"properties": bson.M{
+		"user": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"token_hash": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"expires_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"used_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+var validator = bson.M{
+	"$jsonSchema": jsonSchema,
+}
+
+// Migrate create password_resets collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "password_resets", validator); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("password_resets").Indexes().CreateMany(r.ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"token_hash": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package reset
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Repo is a representation of password reset repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new password reset repository
+func NewRepo(ctx context.Context, db *mongo.Database) RDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("password_resets")
+	return r
+}
+
+// Create password reset to repo
+func (r Repo) Create(rs Reset) error {
+	_, err := r.col.InsertOne(r.ctx, rs)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "create-reset",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// Consume mark the unused and unexpired reset matching the token hash as used and return it
+func (r Repo) Consume(tokenHash string) (Reset, error) {
+	var rs Reset
+	now := time.Now()
+
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_at": now}}
+
+	if err := r.col.FindOneAndUpdate(r.ctx, filter, update).Decode(&rs); err != nil {
+		return rs, repo.ErrRepoOp{
+			Op:   "consuming-reset",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("reset token is invalid or expired"),
+		}
+	}
+	return rs, nil
+}
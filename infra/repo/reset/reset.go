@@ -0,0 +1,25 @@
+package reset
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reset structure representation of a password reset token
+type Reset struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	User      primitive.ObjectID `bson:"user"`
+	TokenHash string             `bson:"token_hash"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+}
+
+// RDB represents password reset repository interface
+type RDB interface {
+	Migrate() error
+
+	Create(rs Reset) error
+	Consume(tokenHash string) (Reset, error)
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"family", "user", "jti", "token_hash", "created_at", "expires_at"},
+	"properties": bson.M{
+		"family": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"user": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"jti": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"token_hash": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"expires_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"rotated_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"revoked_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+var validator = bson.M{
+	"$jsonSchema": jsonSchema,
+}
+
+// Migrate create sessions collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "sessions", validator); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("sessions").Indexes().CreateMany(r.ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.M{"token_hash": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"jti": 1},
+		},
+		{
+			Keys: bson.M{"family": 1},
+		},
+		{
+			Keys:    bson.M{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
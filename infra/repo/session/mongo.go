@@ -0,0 +1,151 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Repo is a representation of session repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new session repository
+func NewRepo(ctx context.Context, db *mongo.Database) SDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("sessions")
+	return r
+}
+
+// Create session to repo
+func (r Repo) Create(s Session) error {
+	_, err := r.col.InsertOne(r.ctx, s)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "create-session",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// Rotate mark the session matching the refresh token hash as used and return it.
+// Presenting an already rotated or revoked token revokes its whole family.
+func (r Repo) Rotate(tokenHash string) (Session, error) {
+	var s Session
+	now := time.Now()
+
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"rotated_at": nil,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"rotated_at": now}}
+
+	err := r.col.FindOneAndUpdate(r.ctx, filter, update).Decode(&s)
+	if err == nil {
+		return s, nil
+	}
+
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return s, repo.ErrRepoOp{
+			Op:   "rotating-session",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during rotating session. got=%w", err),
+		}
+	}
+
+	if err := r.col.FindOne(r.ctx, bson.M{"token_hash": tokenHash}).Decode(&s); err == nil && s.RotatedAt != nil {
+		if err := r.RevokeFamily(s.Family); err != nil {
+			return s, err
+		}
+		return s, repo.ErrRepoOp{
+			Op:   "rotating-session",
+			Code: http.StatusUnauthorized,
+			Err:  fmt.Errorf("refresh token already used, session revoked"),
+		}
+	}
+
+	return s, repo.ErrRepoOp{
+		Op:   "rotating-session",
+		Code: http.StatusUnauthorized,
+		Err:  fmt.Errorf("refresh token is invalid or expired"),
+	}
+}
+
+// RevokeFamily revoke every session issued from the same login
+func (r Repo) RevokeFamily(family primitive.ObjectID) error {
+	_, err := r.col.UpdateMany(
+		r.ctx,
+		bson.M{"family": family, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "revoking-session",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during revoking session. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// RevokeByJTI revoke the family of the session which issued the access token jti
+func (r Repo) RevokeByJTI(jti string) error {
+	var s Session
+
+	if err := r.col.FindOne(r.ctx, bson.M{"jti": jti}).Decode(&s); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "retrieving-session",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving session. got=%w", err),
+		}
+	}
+
+	return r.RevokeFamily(s.Family)
+}
+
+// RevokeUser revoke every session of the user
+func (r Repo) RevokeUser(user primitive.ObjectID) error {
+	_, err := r.col.UpdateMany(
+		r.ctx,
+		bson.M{"user": user, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "revoking-session",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during revoking session. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// IsRevoked reports whether the access token jti belongs to a revoked session
+func (r Repo) IsRevoked(jti string) (bool, error) {
+	n, err := r.col.CountDocuments(r.ctx, bson.M{"jti": jti, "revoked_at": bson.M{"$ne": nil}})
+	if err != nil {
+		return false, repo.ErrRepoOp{
+			Op:   "retrieving-session",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving session. got=%w", err),
+		}
+	}
+	return n > 0, nil
+}
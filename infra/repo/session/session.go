@@ -0,0 +1,32 @@
+package session
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session structure representation of an issued refresh token
+type Session struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Family    primitive.ObjectID `bson:"family"`
+	User      primitive.ObjectID `bson:"user"`
+	JTI       string             `bson:"jti"`
+	TokenHash string             `bson:"token_hash"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	RotatedAt *time.Time         `bson:"rotated_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+}
+
+// SDB represents session repository interface
+type SDB interface {
+	Migrate() error
+
+	Create(s Session) error
+	Rotate(tokenHash string) (Session, error)
+	RevokeFamily(family primitive.ObjectID) error
+	RevokeByJTI(jti string) error
+	RevokeUser(user primitive.ObjectID) error
+	IsRevoked(jti string) (bool, error)
+}
@@ -0,0 +1,49 @@
+package stock
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// movementFields lists fields movements can be filtered and sorted by
+var movementFields = filter.Schema{
+	"kind":       {Kind: filter.String},
+	"order":      {Kind: filter.ID},
+	"editor":     {Kind: filter.ID},
+	"quantity":   {Kind: filter.Number, Sortable: true},
+	"created_at": {Kind: filter.Date, Sortable: true},
+}
+
+func movementsPipe(ref string, f filter.Query) (mongo.Pipeline, error) {
+	var pipeline mongo.Pipeline
+
+	pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.M{"ref": ref}}})
+
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, movementFields)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return mongorepo.PagePipeline(pipeline, f, movementFields, bson.D{primitive.E{Key: "created_at", Value: -1}})
+}
+
+// change return the stock fields increments of a movement, reservations may exceed the
+// quantity on hand so orders are confirmed before stock is keyed in, available goes negative
+func change(m Movement) bson.M {
+	switch m.Kind {
+	case MoveIn:
+		return bson.M{"quantity": m.Quantity}
+	case MoveOut:
+		return bson.M{"quantity": -m.Quantity}
+	case MoveAdjust:
+		return bson.M{"quantity": m.Quantity}
+	case MoveReserve:
+		return bson.M{"reserved": m.Quantity}
+	case MoveRelease:
+		return bson.M{"reserved": -m.Quantity}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package stock
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var stockSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"ref", "quantity", "reserved"},
+	"properties": bson.M{
+		"ref": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"quantity": bson.M{
+			"bsonType":    "number",
+			"description": "must be a number and is required",
+		},
+		"reserved": bson.M{
+			"bsonType":    "number",
+			"description": "must be a number and is required",
+		},
+		"modified_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+var movementSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"created_at", "ref", "kind", "quantity", "editor"},
+	"properties": bson.M{
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"ref": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"kind": bson.M{
+			"enum":        Kinds,
+			"description": "must be a movement kind and is required",
+		},
+		"quantity": bson.M{
+			"bsonType":    "number",
+			"description": "must be a number and is required",
+		},
+		"order": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId",
+		},
+		"editor": bson.M{
+			"bsonType":    "objectId",
+			"description": "must be a objectId and is required",
+		},
+		"note": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string",
+		},
+	},
+}
+
+// Migrate create stocks and stock_movements collections with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "stocks", bson.M{"$jsonSchema": stockSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("stocks").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys:    bson.M{"ref": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "stock_movements", bson.M{"$jsonSchema": movementSchema}); err != nil {
+		return err
+	}
+
+	_, err = r.db.Collection("stock_movements").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys: bson.D{primitive.E{Key: "ref", Value: 1}, primitive.E{Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
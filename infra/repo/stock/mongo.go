@@ -0,0 +1,155 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a representation of stock repository structure
+type Repo struct {
+	db        *mongo.Database
+	ctx       context.Context
+	col       *mongo.Collection
+	movements *mongo.Collection
+}
+
+// NewRepo return a new stock repository
+func NewRepo(ctx context.Context, db *mongo.Database) SDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("stocks")
+	r.movements = r.db.Collection("stock_movements")
+	return r
+}
+
+// Read return stock of product ref, a product never stocked has an empty stock
+func (r Repo) Read(ref string) (Stock, error) {
+	s := Stock{Ref: ref}
+
+	err := r.col.FindOne(r.ctx, bson.M{"ref": ref}).Decode(&s)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return s, repo.ErrRepoOp{
+			Op:   "retrieving-stock",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving stock. got=%w", err),
+		}
+	}
+	return s, nil
+}
+
+// Apply update product stock with movement and record it in history
+func (r Repo) Apply(m Movement) (Stock, error) {
+	var s Stock
+
+	inc := change(m)
+	if inc == nil {
+		return s, repo.ErrRepoOp{
+			Op:   "applying-movement",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("unknown movement kind %v", m.Kind),
+		}
+	}
+
+	filter := bson.M{"ref": m.Ref}
+
+	// upserted stocks start with every counter the movement doesn't increment at zero
+	onInsert := bson.M{}
+	for _, k := range []string{"quantity", "reserved"} {
+		if _, ok := inc[k]; !ok {
+			onInsert[k] = 0
+		}
+	}
+
+	update := bson.M{
+		"$inc":         inc,
+		"$set":         bson.M{"modified_at": m.CreatedAt},
+		"$setOnInsert": onInsert,
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true)
+	after := options.After
+	opts.ReturnDocument = &after
+
+	if err := r.col.FindOneAndUpdate(r.ctx, filter, update, opts).Decode(&s); err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "applying-movement",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during updating stock. got=%w", err),
+		}
+	}
+
+	if _, err := r.movements.InsertOne(r.ctx, m); err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "create-movement",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+
+	return s, nil
+}
+
+// Movements return stock movements history of product ref
+func (r Repo) Movements(ref string, f filter.Query) (pagination.Meta, []Movement, error) {
+	res := struct {
+		Movements []Movement               `bson:"data"`
+		Meta      []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := movementsPipe(ref, f)
+	if err != nil {
+		return meta, res.Movements, err
+	}
+
+	curs, err := r.movements.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Movements, repo.ErrRepoOp{
+			Op:   "movement-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during movement aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Movements, repo.ErrRepoOp{
+			Op:   "retrieving-movement",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving movement. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Movements, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Movements, repo.ErrRepoOp{
+			Op:   "retrieving-movement",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Movements, nil
+}
@@ -0,0 +1,56 @@
+package stock
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Movement kinds
+const (
+	MoveIn      = "in"
+	MoveOut     = "out"
+	MoveAdjust  = "adjust"
+	MoveReserve = "reserve"
+	MoveRelease = "release"
+)
+
+// Kinds lists every known movement kind
+var Kinds = []string{MoveIn, MoveOut, MoveAdjust, MoveReserve, MoveRelease}
+
+// Stock structure representation, quantities are in grams
+type Stock struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Ref        string             `bson:"ref"`
+	Quantity   float64            `bson:"quantity"`
+	Reserved   float64            `bson:"reserved"`
+	ModifiedAt time.Time          `bson:"modified_at"`
+}
+
+// Available return quantity which is not reserved by an order
+func (s Stock) Available() float64 {
+	return s.Quantity - s.Reserved
+}
+
+// Movement structure representation, quantity is in grams
+type Movement struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty"`
+	CreatedAt time.Time           `bson:"created_at"`
+	Ref       string              `bson:"ref"`
+	Kind      string              `bson:"kind"`
+	Quantity  float64             `bson:"quantity"`
+	Order     *primitive.ObjectID `bson:"order,omitempty"`
+	Editor    primitive.ObjectID  `bson:"editor"`
+	Note      string              `bson:"note,omitempty"`
+}
+
+// SDB represents stock repository interface
+type SDB interface {
+	Migrate() error
+
+	Read(ref string) (Stock, error)
+	Apply(m Movement) (Stock, error)
+	Movements(ref string, f filter.Query) (pagination.Meta, []Movement, error)
+}
@@ -0,0 +1,38 @@
+package subscription
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// schema lists fields subscriptions can be filtered and sorted by
+var schema = filter.Schema{
+	"created_at":            {Kind: filter.Date, Sortable: true},
+	"relationShip.customer": {Kind: filter.ID},
+	"rule.frequency":        {Kind: filter.String},
+	"rule.weekday":          {Kind: filter.Number, Sortable: true},
+	"rule.at":               {Kind: filter.String, Sortable: true},
+	"products.product_id":   {Kind: filter.ID},
+}
+
+func listPipe(customer primitive.ObjectID, f filter.Query) (mongo.Pipeline, error) {
+	var pipeline mongo.Pipeline
+
+	pipeline = mongorepo.AlivePipeline(pipeline, f.WithDeleted)
+
+	if customer != primitive.NilObjectID {
+		pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.M{
+			"relationShip.customer": customer,
+		}}})
+	}
+
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return mongorepo.PagePipeline(pipeline, f, schema, bson.D{primitive.E{Key: "created_at", Value: -1}})
+}
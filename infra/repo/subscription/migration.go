@@ -0,0 +1,132 @@
+package subscription
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/recurrence"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"created_at", "relationShip", "products", "rule"},
+	"properties": bson.M{
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date and is required",
+		},
+		"modified_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"deleted_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"version": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int",
+		},
+		"relationShip": bson.M{
+			"bsonType":    "object",
+			"description": "must be an object and is required",
+			"required":    []string{"customer", "editor"},
+			"properties": bson.M{
+				"customer": bson.M{
+					"bsonType":    "objectId",
+					"description": "must be a objectId and is required",
+				},
+				"editor": bson.M{
+					"bsonType":    "objectId",
+					"description": "must be a objectId and is required",
+				},
+			},
+		},
+		"products": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array and is required",
+			"minItems":    1,
+			"items": bson.M{
+				"bsonType":    "object",
+				"description": "must be an object and is required",
+				"required":    []string{"quantity", "unit", "product_id"},
+				"properties": bson.M{
+					"quantity": bson.M{
+						"bsonType":    "double",
+						"description": "must be a double and is required",
+					},
+					"unit": bson.M{
+						"enum":        []string{"gr", "p"},
+						"description": "must be a string and is required",
+					},
+					"product_id": bson.M{
+						"bsonType":    "objectId",
+						"description": "must be a objectId and is required",
+					},
+					"option": bson.M{
+						"bsonType":    "string",
+						"description": "must be a string",
+					},
+				},
+			},
+		},
+		"rule": bson.M{
+			"bsonType":    "object",
+			"description": "must be an object and is required",
+			"required":    []string{"frequency", "weekday", "at", "start"},
+			"properties": bson.M{
+				"frequency": bson.M{
+					"enum":        recurrence.Frequencies,
+					"description": "must be a valid frequency and is required",
+				},
+				"weekday": bson.M{
+					"bsonType":    "int",
+					"minimum":     0,
+					"maximum":     6,
+					"description": "must be a weekday between 0 and 6 and is required",
+				},
+				"at": bson.M{
+					"bsonType":    "string",
+					"pattern":     "^([01][0-9]|2[0-3]):[0-5][0-9]$",
+					"description": "must be a 15:04 time and is required",
+				},
+				"start": bson.M{
+					"bsonType":    "date",
+					"description": "must be a date and is required",
+				},
+			},
+		},
+		"pauses": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array of dates",
+			"items": bson.M{
+				"bsonType": "date",
+			},
+		},
+		"generated_until": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+// Migrate create subscriptions collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, collection, bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.col.Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "relationShip.customer", Value: 1},
+			primitive.E{Key: "created_at", Value: -1},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
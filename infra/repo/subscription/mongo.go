@@ -0,0 +1,257 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collection = "subscriptions"
+
+// Repo is a representation of subscription repository structure
+type Repo struct {
+	db    *mongo.Database
+	ctx   context.Context
+	col   *mongo.Collection
+	audit audit.Recorder
+}
+
+// NewRepo return a new subscription repository
+func NewRepo(ctx context.Context, db *mongo.Database) SDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection(collection)
+	r.audit = audit.NewRecorder(ctx, db, collection)
+	return r
+}
+
+// List return a list of subscriptions, restricted to the customer unless nil
+func (r Repo) List(f filter.Query, customer primitive.ObjectID) (pagination.Meta, []Subscription, error) {
+	res := struct {
+		Subscriptions []Subscription           `bson:"data"`
+		Meta          []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := listPipe(customer, f)
+	if err != nil {
+		return meta, res.Subscriptions, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Subscriptions, repo.ErrRepoOp{
+			Op:   "subscription-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during subscription aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Subscriptions, repo.ErrRepoOp{
+			Op:   "retrieving-subscription",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving subscription. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Subscriptions, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Subscriptions, repo.ErrRepoOp{
+			Op:   "retrieving-subscription",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Subscriptions, nil
+}
+
+// Read return subscription by id
+func (r Repo) Read(id string) (Subscription, error) {
+	var s Subscription
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "parsing-subscription-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	if err := r.col.FindOne(r.ctx, mongorepo.Alive(bson.M{"_id": uid})).Decode(&s); err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "retrieving-subscription",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("subscription not found. id=%v doesn't exist", id),
+		}
+	}
+	return s, nil
+}
+
+// Create subscription to repo
+func (r Repo) Create(s Subscription) error {
+	res, err := r.col.InsertOne(r.ctx, s)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "create-subscription",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+
+	uid, _ := res.InsertedID.(primitive.ObjectID)
+	r.audit.Created(uid, s)
+	return nil
+}
+
+// UpdateFields replace the products, rule and pauses of a subscription
+func (r Repo) UpdateFields(id string, upd Subscription, version int) (Subscription, error) {
+	var s Subscription
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "parsing-subscription-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	filter := mongorepo.Alive(bson.M{"_id": uid})
+
+	var before Subscription
+	if err := r.col.FindOne(r.ctx, filter).Decode(&before); err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "updating-subscription",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("subscription not found. id=%v doesn't exist", id),
+		}
+	}
+
+	if mongorepo.Stale(version, before.Version) {
+		return s, mongorepo.VersionErr("updating-subscription", version, before.Version)
+	}
+	filter = mongorepo.Expect(filter, version)
+
+	if upd.Pauses == nil {
+		upd.Pauses = []time.Time{}
+	}
+
+	// generated orders stay as they are, the new rule applies after them
+	update := mongorepo.Bump(bson.M{"$set": bson.M{
+		"products":    upd.ProductsLines,
+		"rule":        upd.Rule,
+		"pauses":      upd.Pauses,
+		"modified_at": time.Now(),
+	}})
+
+	opts := options.FindOneAndUpdate()
+	after := options.After
+	opts.ReturnDocument = &after
+
+	err = r.col.FindOneAndUpdate(r.ctx, filter, update, opts).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) && version != repo.AnyVersion {
+		return s, mongorepo.VersionErr("updating-subscription", version, before.Version)
+	}
+	if err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "updating-subscription",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	r.audit.Updated(uid, before, s)
+	return s, nil
+}
+
+// Delete subscription by id, orders already generated are kept
+func (r Repo) Delete(id string) error {
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "parsing-subscription-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	var before Subscription
+	if err := mongorepo.SoftDelete(r.ctx, r.col, uid).Decode(&before); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "deleting-subscription",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during deleting subscription. got=%w", err),
+		}
+	}
+
+	r.audit.Deleted(uid, before)
+	return nil
+}
+
+// Active return every subscription which is not deleted
+func (r Repo) Active() ([]Subscription, error) {
+	var ss []Subscription
+
+	curs, err := r.col.Find(r.ctx, mongorepo.Alive(bson.M{}))
+	if err != nil {
+		return ss, repo.ErrRepoOp{
+			Op:   "retrieving-subscription",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving subscription. got=%w", err),
+		}
+	}
+
+	if err := curs.All(r.ctx, &ss); err != nil {
+		return ss, repo.ErrRepoOp{
+			Op:   "retrieving-subscription",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving subscription. got=%w", err),
+		}
+	}
+
+	return ss, nil
+}
+
+// MarkGenerated record that orders were generated up to until, it is not a user change
+// so neither the version nor the audit log are touched
+func (r Repo) MarkGenerated(id primitive.ObjectID, until time.Time) error {
+	update := bson.M{"$set": bson.M{"generated_until": until}}
+
+	if _, err := r.col.UpdateOne(r.ctx, bson.M{"_id": id}, update); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "updating-subscription",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during marking subscription generated. got=%w", err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package subscription
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"github.com/valensto/api_apbp/pkg/recurrence"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Subscription structure representation of a standing order generated on a recurrence
+type Subscription struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	ModifiedAt     time.Time          `bson:"modified_at"`
+	DeletedAt      *time.Time         `bson:"deleted_at,omitempty"`
+	Version        int                `bson:"version,omitempty"`
+	RelationShip   RelationShip       `bson:"relationShip"`
+	ProductsLines  []Line             `bson:"products"`
+	Rule           Rule               `bson:"rule"`
+	Pauses         []time.Time        `bson:"pauses,omitempty"`
+	GeneratedUntil *time.Time         `bson:"generated_until,omitempty"`
+}
+
+// RelationShip structure representation
+type RelationShip struct {
+	Customer primitive.ObjectID `bson:"customer"`
+	Editor   primitive.ObjectID `bson:"editor"`
+}
+
+// Line is a product line as ordered, it is priced when its order is generated
+type Line struct {
+	Quantity  float32            `bson:"quantity"`
+	Unit      string             `bson:"unit"`
+	ProductID primitive.ObjectID `bson:"product_id"`
+	Option    string             `bson:"option,omitempty"`
+}
+
+// Rule structure representation of the recurrence, at is the pick up time as 15:04
+type Rule struct {
+	Frequency string    `bson:"frequency"`
+	Weekday   int       `bson:"weekday"`
+	At        string    `bson:"at"`
+	Start     time.Time `bson:"start"`
+}
+
+// Recurrence return the rule generating pick up times
+func (r Rule) Recurrence() recurrence.Rule {
+	return recurrence.Rule{
+		Frequency: r.Frequency,
+		Weekday:   time.Weekday(r.Weekday),
+		At:        r.At,
+		Start:     r.Start,
+	}
+}
+
+// SDB represents subscription repository interface
+type SDB interface {
+	Migrate() error
+
+	List(f filter.Query, customer primitive.ObjectID) (pagination.Meta, []Subscription, error)
+	Read(id string) (Subscription, error)
+	Create(s Subscription) error
+	UpdateFields(id string, upd Subscription, version int) (Subscription, error)
+	Delete(id string) error
+
+	Active() ([]Subscription, error)
+	MarkGenerated(id primitive.ObjectID, until time.Time) error
+}
@@ -0,0 +1,72 @@
+package supplier
+
+import (
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var jsonSchema = bson.M{
+	"bsonType": "object",
+	"required": []string{"name", "refs", "lead_time"},
+	"properties": bson.M{
+		"name": bson.M{
+			"bsonType":    "string",
+			"description": "must be a string and is required",
+		},
+		"contact": bson.M{
+			"bsonType":    "object",
+			"description": "must be an object",
+			"properties": bson.M{
+				"name": bson.M{
+					"bsonType":    "string",
+					"description": "must be a string",
+				},
+				"email": bson.M{
+					"bsonType":    "string",
+					"description": "must be a string",
+				},
+				"phone": bson.M{
+					"bsonType":    "string",
+					"description": "must be a string",
+				},
+			},
+		},
+		"refs": bson.M{
+			"bsonType":    "array",
+			"description": "must be an array of product refs and is required",
+			"items": bson.M{
+				"bsonType": "string",
+			},
+		},
+		"lead_time": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int and is required",
+		},
+		"created_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+		"modified_at": bson.M{
+			"bsonType":    "date",
+			"description": "must be a date",
+		},
+	},
+}
+
+// Migrate create suppliers collection with schema and indexs
+func (r *Repo) Migrate() error {
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "suppliers", bson.M{"$jsonSchema": jsonSchema}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("suppliers").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+		Keys: bson.M{"refs": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package supplier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a representation of supplier repository structure
+type Repo struct {
+	db  *mongo.Database
+	ctx context.Context
+	col *mongo.Collection
+}
+
+// NewRepo return a new supplier repository
+func NewRepo(ctx context.Context, db *mongo.Database) SDB {
+	r := &Repo{
+		db:  db,
+		ctx: ctx,
+	}
+	r.col = r.db.Collection("suppliers")
+	return r
+}
+
+// List return a list of suppliers
+func (r Repo) List(f filter.Query) (pagination.Meta, []Supplier, error) {
+	res := struct {
+		Suppliers []Supplier               `bson:"data"`
+		Meta      []map[string]interface{} `bson:"meta"`
+	}{}
+
+	meta := pagination.Meta{}
+
+	pipeline, err := listPipe(f)
+	if err != nil {
+		return meta, res.Suppliers, err
+	}
+
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return meta, res.Suppliers, repo.ErrRepoOp{
+			Op:   "supplier-aggregation",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during supplier aggregation. got=%w", err),
+		}
+	}
+
+	for curs.Next(r.ctx) {
+		if err = curs.Decode(&res); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := curs.Err(); err != nil {
+		return meta, res.Suppliers, repo.ErrRepoOp{
+			Op:   "retrieving-supplier",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving supplier. got=%w", err),
+		}
+	}
+
+	if len(res.Meta) <= 0 {
+		return meta, res.Suppliers, nil
+	}
+
+	meta, err = pagination.NewMeta(res.Meta[0])
+	if err != nil {
+		return meta, res.Suppliers, repo.ErrRepoOp{
+			Op:   "retrieving-supplier",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating meta pagination. got=%w", err),
+		}
+	}
+
+	return meta, res.Suppliers, nil
+}
+
+// Supplying return suppliers of any of the product refs, shortest lead time first
+func (r Repo) Supplying(refs []string) ([]Supplier, error) {
+	var ss []Supplier
+
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "lead_time", Value: 1}})
+
+	curs, err := r.col.Find(r.ctx, bson.M{"refs": bson.M{"$in": refs}}, opts)
+	if err != nil {
+		return ss, repo.ErrRepoOp{
+			Op:   "retrieving-supplier",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving supplier. got=%w", err),
+		}
+	}
+
+	if err := curs.All(r.ctx, &ss); err != nil {
+		return ss, repo.ErrRepoOp{
+			Op:   "retrieving-supplier",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during retrieving supplier. got=%w", err),
+		}
+	}
+
+	return ss, nil
+}
+
+// Read return supplier by id
+func (r Repo) Read(id string) (Supplier, error) {
+	s := Supplier{}
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "parsing-supplier-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&s); err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "retrieving-supplier",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving supplier. got=%w", err),
+		}
+	}
+	return s, nil
+}
+
+// Create supplier to repo
+func (r Repo) Create(s Supplier) error {
+	_, err := r.col.InsertOne(r.ctx, s)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "create-supplier",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during creating. got=%w", err),
+		}
+	}
+	return nil
+}
+
+// UpdateFields supplier from repo
+func (r Repo) UpdateFields(id string, upd Supplier) (Supplier, error) {
+	var s Supplier
+
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "parsing-supplier-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":        upd.Name,
+			"contact":     upd.Contact,
+			"refs":        upd.Refs,
+			"lead_time":   upd.LeadTime,
+			"modified_at": time.Now(),
+		},
+	}
+
+	opts := options.FindOneAndUpdate()
+	after := options.After
+	opts.ReturnDocument = &after
+
+	if err := r.col.FindOneAndUpdate(r.ctx, bson.M{"_id": uid}, update, opts).Decode(&s); err != nil {
+		return s, repo.ErrRepoOp{
+			Op:   "updating-supplier",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	return s, nil
+}
+
+// Delete supplier by id
+func (r Repo) Delete(id string) error {
+	uid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return repo.ErrRepoOp{
+			Op:   "parsing-supplier-id",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
+		}
+	}
+
+	if _, err := r.col.DeleteOne(r.ctx, bson.M{"_id": uid}); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "deleting-supplier",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during deleting supplier. got=%w", err),
+		}
+	}
+	return nil
+}
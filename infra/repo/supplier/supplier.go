@@ -0,0 +1,39 @@
+package supplier
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/filter"
+	"github.com/valensto/api_apbp/pkg/pagination"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Supplier structure representation, lead time is in days
+type Supplier struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+	ModifiedAt time.Time          `bson:"modified_at"`
+	Name       string             `bson:"name"`
+	Contact    Contact            `bson:"contact"`
+	Refs       []string           `bson:"refs"`
+	LeadTime   int                `bson:"lead_time"`
+}
+
+// Contact structure representation
+type Contact struct {
+	Name  string `bson:"name,omitempty"`
+	Email string `bson:"email,omitempty"`
+	Phone string `bson:"phone,omitempty"`
+}
+
+// SDB represents supplier repository interface
+type SDB interface {
+	Migrate() error
+
+	Read(id string) (Supplier, error)
+	Delete(id string) error
+	List(f filter.Query) (pagination.Meta, []Supplier, error)
+	Supplying(refs []string) ([]Supplier, error)
+	Create(s Supplier) error
+	UpdateFields(id string, upd Supplier) (Supplier, error)
+}
@@ -9,17 +9,32 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func listPipe(f filter.Query, admin bool) mongo.Pipeline {
+// schema lists fields users can be filtered and sorted by
+var schema = filter.Schema{
+	"lastname":    {Kind: filter.String, Sortable: true},
+	"firstname":   {Kind: filter.String, Sortable: true},
+	"email":       {Kind: filter.String, Sortable: true},
+	"phone":       {Kind: filter.String},
+	"role":        {Kind: filter.String},
+	"created_at":  {Kind: filter.Date, Sortable: true},
+	"modified_at": {Kind: filter.Date, Sortable: true},
+}
+
+func listPipe(f filter.Query, admin bool) (mongo.Pipeline, error) {
 	var pipeline mongo.Pipeline
 
-	pipeline = searchTerm(pipeline, f.Term)
+	pipeline = mongorepo.TextPipeline(pipeline, f.Term)
 
-	pipeline = append(pipeline, bson.D{primitive.E{Key: "$match", Value: bson.M{"delete_at": nil}}})
+	pipeline = mongorepo.AlivePipeline(pipeline, f.WithDeleted)
 
 	pipeline = hasAdmin(pipeline, admin)
-	pipeline = mongorepo.PaginatePipeline(pipeline, f.Pagination)
 
-	return pipeline
+	pipeline, err := mongorepo.FilterPipeline(pipeline, f, schema)
+	if err != nil {
+		return pipeline, err
+	}
+
+	return mongorepo.PagePipeline(pipeline, f, schema, mongorepo.TextSort(f.Term, nil))
 }
 
 func hasAdmin(pipeline mongo.Pipeline, b bool) mongo.Pipeline {
@@ -30,46 +45,3 @@ func hasAdmin(pipeline mongo.Pipeline, b bool) mongo.Pipeline {
 
 	return append(pipeline, hasAdmin)
 }
-
-func searchTerm(pipeline mongo.Pipeline, str string) mongo.Pipeline {
-	if str != "" {
-		pipeline = append(pipeline, bson.D{primitive.E{
-			Key: "$match",
-			Value: bson.D{primitive.E{
-				Key: "$or",
-				Value: bson.A{
-					bson.D{primitive.E{
-						Key: "lastname",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-					bson.D{primitive.E{
-						Key: "firstname",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-					bson.D{primitive.E{
-						Key: "email",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-					bson.D{primitive.E{
-						Key: "phone",
-						Value: bson.M{
-							"$regex":   str,
-							"$options": "i",
-						},
-					}},
-				},
-			}},
-		}})
-	}
-
-	return pipeline
-}
@@ -3,7 +3,9 @@ package user
 import (
 	"time"
 
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -48,6 +50,11 @@ var jsonSchema = bson.M{
 			"bsonType":    "date",
 			"description": "must be a date and is required",
 		},
+		"version": bson.M{
+			"bsonType":    "int",
+			"minimum":     0,
+			"description": "must be a positive int",
+		},
 		"address": bson.M{
 			"bsonType":    "object",
 			"description": "must be an object",
@@ -80,13 +87,20 @@ var validator = bson.M{
 
 // Migrate create users collection with schema and indexs
 func (r *Repo) Migrate() error {
-	opts := options.CreateCollection().SetValidator(validator)
+	if err := mongorepo.CreateCollection(r.ctx, r.db, "users", validator); err != nil {
+		return err
+	}
 
-	if err := r.db.CreateCollection(r.ctx, "users", opts); err != nil {
+	// users deleted before soft delete was shared were marked with delete_at
+	_, err := r.db.Collection("users").UpdateMany(r.ctx,
+		bson.M{"delete_at": bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{"delete_at": mongorepo.DeletedAt}},
+	)
+	if err != nil {
 		return err
 	}
 
-	_, err := r.db.Collection("users").Indexes().CreateOne(r.ctx, mongo.IndexModel{
+	_, err = r.db.Collection("users").Indexes().CreateOne(r.ctx, mongo.IndexModel{
 		Keys:    bson.M{"email": 1},
 		Options: options.Index().SetUnique(true).SetSparse(true),
 	})
@@ -102,6 +116,25 @@ func (r *Repo) Migrate() error {
 		return err
 	}
 
+	_, err = r.db.Collection("users").Indexes().CreateOne(r.ctx, mongorepo.TextIndex(bson.D{
+		primitive.E{Key: "lastname", Value: 10},
+		primitive.E{Key: "firstname", Value: 10},
+		primitive.E{Key: "email", Value: 5},
+		primitive.E{Key: "phone", Value: 2},
+	}))
+	if err != nil {
+		return err
+	}
+
+	// the admin is only seeded once so migrations can run again on a deployed database
+	n, err := r.db.Collection("users").CountDocuments(r.ctx, bson.M{"email": "admin@exemple.com"})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
 	u := User{
 		CreatedAt: time.Now(),
 		Lastname:  "admin",
@@ -2,12 +2,15 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/valensto/api_apbp/infra/repo"
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	mongorepo "github.com/valensto/api_apbp/infra/repo/mongo"
 	"github.com/valensto/api_apbp/pkg/filter"
 	"github.com/valensto/api_apbp/pkg/pagination"
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,9 +22,10 @@ import (
 
 // Repo is a representation of user repository structure
 type Repo struct {
-	db  *mongo.Database
-	col *mongo.Collection
-	ctx context.Context
+	db    *mongo.Database
+	col   *mongo.Collection
+	ctx   context.Context
+	audit audit.Recorder
 }
 
 // NewRepo return a new user repository
@@ -31,6 +35,7 @@ func NewRepo(ctx context.Context, db *mongo.Database) UDB {
 		ctx: ctx,
 	}
 	r.col = r.db.Collection("users")
+	r.audit = audit.NewRecorder(ctx, db, "users", "password")
 	return r
 }
 
@@ -38,7 +43,7 @@ func NewRepo(ctx context.Context, db *mongo.Database) UDB {
 func (r Repo) FindByCredential(email string) (User, error) {
 	usr := User{}
 
-	if err := r.col.FindOne(r.ctx, bson.M{"email": email}).Decode(&usr); err != nil {
+	if err := r.col.FindOne(r.ctx, mongorepo.Alive(bson.M{"email": email})).Decode(&usr); err != nil {
 		return usr, repo.ErrRepoOp{
 			Op:   "retrieving-user",
 			Code: http.StatusBadRequest,
@@ -50,7 +55,12 @@ func (r Repo) FindByCredential(email string) (User, error) {
 
 // List return a list of users
 func (r Repo) List(f filter.Query, admin bool) (pagination.Meta, []User, error) {
-	total, users, err := r.retrieve(f, listPipe(f, admin))
+	pipeline, err := listPipe(f, admin)
+	if err != nil {
+		return pagination.Meta{}, nil, err
+	}
+
+	total, users, err := r.retrieve(f, pipeline)
 	if err != nil {
 		return total, users, err
 	}
@@ -69,7 +79,7 @@ func (r Repo) Read(id string) (User, error) {
 		}
 	}
 
-	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid, "delete_at": nil}).Decode(&usr); err != nil {
+	if err := r.col.FindOne(r.ctx, mongorepo.Alive(bson.M{"_id": uid})).Decode(&usr); err != nil {
 		return usr, repo.ErrRepoOp{
 			Op:   "retrieving-user",
 			Code: http.StatusInternalServerError,
@@ -136,7 +146,7 @@ func (r Repo) Create(usr User) error {
 		usr.Password = pwd
 	}
 
-	_, err := r.col.InsertOne(r.ctx, usr)
+	res, err := r.col.InsertOne(r.ctx, usr)
 	if err != nil {
 		return repo.ErrRepoOp{
 			Op:   "create-user",
@@ -144,11 +154,14 @@ func (r Repo) Create(usr User) error {
 			Err:  fmt.Errorf("error occured during creating. got=%w", err),
 		}
 	}
+
+	uid, _ := res.InsertedID.(primitive.ObjectID)
+	r.audit.Created(uid, usr)
 	return nil
 }
 
 // UpdateFields user from repo
-func (r Repo) UpdateFields(id string, updUsr interface{}) (User, error) {
+func (r Repo) UpdateFields(id string, updUsr interface{}, version int) (User, error) {
 	update := []bson.D{
 		{primitive.E{
 			Key:   "$set",
@@ -163,20 +176,11 @@ func (r Repo) UpdateFields(id string, updUsr interface{}) (User, error) {
 		}},
 	}
 
-	u, err := r.update(id, update)
-	if err != nil {
-		return u, repo.ErrRepoOp{
-			Op:   "updating-user",
-			Code: http.StatusBadRequest,
-			Err:  fmt.Errorf("error occured during updating. got=%w", err),
-		}
-	}
-
-	return u, nil
+	return r.update(id, update, version)
 }
 
 // UpdateField user from repo
-func (r Repo) UpdateField(id, field string, v interface{}) (User, error) {
+func (r Repo) UpdateField(id, field string, v interface{}, version int) (User, error) {
 	update := []bson.D{
 		{primitive.E{
 			Key: "$set",
@@ -197,19 +201,10 @@ func (r Repo) UpdateField(id, field string, v interface{}) (User, error) {
 		}},
 	}
 
-	u, err := r.update(id, update)
-	if err != nil {
-		return u, repo.ErrRepoOp{
-			Op:   "updating-user",
-			Code: http.StatusBadRequest,
-			Err:  fmt.Errorf("error occured during updating. got=%w", err),
-		}
-	}
-
-	return u, nil
+	return r.update(id, update, version)
 }
 
-func (r Repo) update(id string, update []bson.D) (User, error) {
+func (r Repo) update(id string, update []bson.D, version int) (User, error) {
 	var u User
 
 	uid, err := primitive.ObjectIDFromHex(id)
@@ -221,7 +216,23 @@ func (r Repo) update(id string, update []bson.D) (User, error) {
 		}
 	}
 
-	filter := bson.M{"_id": bson.M{"$eq": uid}, "deleted_at": nil}
+	filter := mongorepo.Alive(bson.M{"_id": bson.M{"$eq": uid}})
+
+	var before User
+	if err := r.col.FindOne(r.ctx, filter).Decode(&before); err != nil {
+		return u, repo.ErrRepoOp{
+			Op:   "updating-user",
+			Code: http.StatusBadRequest,
+			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+		}
+	}
+
+	if mongorepo.Stale(version, before.Version) {
+		return u, mongorepo.VersionErr("updating-user", version, before.Version)
+	}
+	filter = mongorepo.Expect(filter, version)
+
+	update = append(update, mongorepo.BumpStage())
 
 	opts := options.FindOneAndUpdate()
 	after := options.After
@@ -233,6 +244,9 @@ func (r Repo) update(id string, update []bson.D) (User, error) {
 		update,
 		opts,
 	)
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) && version != repo.AnyVersion {
+		return u, mongorepo.VersionErr("updating-user", version, before.Version)
+	}
 	if res.Err() != nil {
 		return u, repo.ErrRepoOp{
 			Op:   "updating-user",
@@ -249,6 +263,7 @@ func (r Repo) update(id string, update []bson.D) (User, error) {
 		}
 	}
 
+	r.audit.Updated(uid, before, u)
 	return u, nil
 }
 
@@ -263,27 +278,108 @@ func (r Repo) Delete(id string) error {
 		}
 	}
 
-	filter := bson.M{"_id": bson.M{"$eq": uid}}
-	update := bson.M{
-		"_id":         uid,
-		"delete_at":   time.Now(),
-		"modified_at": time.Now(),
-		"phone":       primitive.NewObjectID().Hex(),
-		"lastname":    "",
-		"firstname":   "",
-		"role":        "customer",
+	var before User
+	if err := mongorepo.SoftDelete(r.ctx, r.col, uid).Decode(&before); err != nil {
+		return repo.ErrRepoOp{
+			Op:   "deleting-user",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during deleting user. got=%w", err),
+		}
 	}
-	_, err = r.col.ReplaceOne(
-		r.ctx,
-		filter,
-		update,
-	)
+
+	r.audit.Deleted(uid, before)
+	return nil
+}
+
+// Restore user soft deleted by id
+func (r Repo) Restore(id string) (User, error) {
+	var u User
+
+	uid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return repo.ErrRepoOp{
-			Op:   "updating-user",
+		return u, repo.ErrRepoOp{
+			Op:   "parsing-user-id",
 			Code: http.StatusBadRequest,
-			Err:  fmt.Errorf("error occured during updating. got=%w", err),
+			Err:  fmt.Errorf("id format is not correct. got=%w", err),
 		}
 	}
-	return nil
+
+	var before User
+	if err := r.col.FindOne(r.ctx, bson.M{"_id": uid}).Decode(&before); err != nil {
+		return u, repo.ErrRepoOp{
+			Op:   "restoring-user",
+			Code: http.StatusNotFound,
+			Err:  fmt.Errorf("error occured during retrieving user. got=%w", err),
+		}
+	}
+
+	if err := mongorepo.Restore(r.ctx, r.col, uid).Decode(&u); err != nil {
+		return u, repo.ErrRepoOp{
+			Op:   "restoring-user",
+			Code: http.StatusConflict,
+			Err:  fmt.Errorf("only deleted users can be restored. got=%w", err),
+		}
+	}
+
+	r.audit.Updated(uid, before, u)
+	return u, nil
+}
+
+// Purge hard delete users soft deleted before the given time. Users still referenced
+// as customer or editor by an order or a subscription are kept until those are gone
+func (r Repo) Purge(before time.Time) (int64, error) {
+	referenced := func(from string) bson.D {
+		return bson.D{primitive.E{Key: "$lookup", Value: bson.M{
+			"from": from,
+			"let":  bson.M{"user": "$_id"},
+			"pipeline": mongo.Pipeline{
+				{primitive.E{Key: "$match", Value: bson.M{"$expr": bson.M{"$or": bson.A{
+					bson.M{"$eq": bson.A{"$relationShip.customer", "$$user"}},
+					bson.M{"$eq": bson.A{"$relationShip.editor", "$$user"}},
+				}}}}},
+				{primitive.E{Key: "$limit", Value: 1}},
+				{primitive.E{Key: "$project", Value: bson.M{"_id": 1}}},
+			},
+			"as": from,
+		}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: bson.M{mongorepo.DeletedAt: bson.M{"$lte": before}}}},
+		referenced("orders"),
+		referenced("subscriptions"),
+		{primitive.E{Key: "$match", Value: bson.M{"orders": bson.A{}, "subscriptions": bson.A{}}}},
+		{primitive.E{Key: "$project", Value: bson.M{"_id": 1}}},
+	}
+
+	var users []User
+	curs, err := r.col.Aggregate(r.ctx, pipeline)
+	if err == nil {
+		err = curs.All(r.ctx, &users)
+	}
+	if err != nil {
+		return 0, repo.ErrRepoOp{
+			Op:   "purging-users",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during looking up purgeable users. got=%w", err),
+		}
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+
+	res, err := r.col.DeleteMany(r.ctx, bson.M{"_id": bson.M{"$in": ids}, mongorepo.DeletedAt: bson.M{"$lte": before}})
+	if err != nil {
+		return 0, repo.ErrRepoOp{
+			Op:   "purging-users",
+			Code: http.StatusInternalServerError,
+			Err:  fmt.Errorf("error occured during purging users. got=%w", err),
+		}
+	}
+	return res.DeletedCount, nil
 }
@@ -15,6 +15,7 @@ type User struct {
 	CreatedAt  time.Time          `bson:"created_at"`
 	ModifiedAt *time.Time         `bson:"modified_at,omitempty"`
 	DeletedAt  *time.Time         `bson:"deleted_at,omitempty"`
+	Version    int                `bson:"version,omitempty"`
 	Lastname   string             `bson:"lastname"`
 	Firstname  string             `bson:"firstname"`
 	Phone      string             `bson:"phone,omitempty"`
@@ -22,6 +23,7 @@ type User struct {
 	Password   string             `bson:"password,omitempty"`
 	Address    *Addr              `bson:"address,omitempty"`
 	Role       string             `bson:"role"`
+	Score      float64            `bson:"score,omitempty"`
 }
 
 // Addr structure representation
@@ -48,8 +50,10 @@ type UDB interface {
 	FindByCredential(email string) (User, error)
 	Read(id string) (User, error)
 	Delete(id string) error
+	Restore(id string) (User, error)
+	Purge(before time.Time) (int64, error)
 	List(f filter.Query, admin bool) (pagination.Meta, []User, error)
 	Create(s User) error
-	UpdateFields(id string, updUsr interface{}) (User, error)
-	UpdateField(id, field string, v interface{}) (User, error)
+	UpdateFields(id string, updUsr interface{}, version int) (User, error)
+	UpdateField(id, field string, v interface{}, version int) (User, error)
 }
@@ -0,0 +1,9 @@
+package repo
+
+import "errors"
+
+// AnyVersion skips the version check of an update
+const AnyVersion = -1
+
+// ErrVersion is returned when a document was updated since the version an update expects
+var ErrVersion = errors.New("document was modified since the expected version")
@@ -5,8 +5,20 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	"github.com/valensto/api_apbp/infra/repo/booking"
+	"github.com/valensto/api_apbp/infra/repo/category"
+	"github.com/valensto/api_apbp/infra/repo/counter"
+	"github.com/valensto/api_apbp/infra/repo/idempotency"
 	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/infra/repo/outbox"
 	"github.com/valensto/api_apbp/infra/repo/product"
+	"github.com/valensto/api_apbp/infra/repo/purchase"
+	"github.com/valensto/api_apbp/infra/repo/reset"
+	"github.com/valensto/api_apbp/infra/repo/session"
+	"github.com/valensto/api_apbp/infra/repo/stock"
+	"github.com/valensto/api_apbp/infra/repo/subscription"
+	"github.com/valensto/api_apbp/infra/repo/supplier"
 	"github.com/valensto/api_apbp/infra/repo/user"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -52,23 +64,116 @@ func (s DBStore) Close() error {
 	return nil
 }
 
+// WithContext return a copy of the store whose repositories run with ctx,
+// its deadline and audit actor included
+func (s *DBStore) WithContext(ctx context.Context) Store {
+	c := *s
+	c.Ctx = ctx
+	return &c
+}
+
+// opTimeout bounds every repository operation
+const opTimeout = 5 * time.Second
+
+// context return the store context, audit actor included, bounded by the operation timeout.
+// Repositories don't hand back a cancel so it is released once the timeout fires
+func (s DBStore) context() context.Context {
+	parent := s.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(parent, opTimeout)
+	time.AfterFunc(opTimeout, cancel)
+	return ctx
+}
+
 // User is a representation of user repository
 func (s DBStore) User() user.UDB {
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	ur := user.NewRepo(ctx, s.DB)
+	ur := user.NewRepo(s.context(), s.DB)
 	return ur
 }
 
 // Product is a representation of product repository
 func (s DBStore) Product() product.PDB {
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	pr := product.NewRepo(ctx, s.DB)
+	pr := product.NewRepo(s.context(), s.DB)
 	return pr
 }
 
 // Order is a representation of product repository
 func (s DBStore) Order() order.ODB {
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	or := order.NewRepo(ctx, s.DB)
+	or := order.NewRepo(s.context(), s.DB)
 	return or
 }
+
+// Session is a representation of session repository
+func (s DBStore) Session() session.SDB {
+	sr := session.NewRepo(s.context(), s.DB)
+	return sr
+}
+
+// Reset is a representation of password reset repository
+func (s DBStore) Reset() reset.RDB {
+	rr := reset.NewRepo(s.context(), s.DB)
+	return rr
+}
+
+// Stock is a representation of stock repository
+func (s DBStore) Stock() stock.SDB {
+	sr := stock.NewRepo(s.context(), s.DB)
+	return sr
+}
+
+// Supplier is a representation of supplier repository
+func (s DBStore) Supplier() supplier.SDB {
+	sr := supplier.NewRepo(s.context(), s.DB)
+	return sr
+}
+
+// Purchase is a representation of purchase repository
+func (s DBStore) Purchase() purchase.PDB {
+	pr := purchase.NewRepo(s.context(), s.DB)
+	return pr
+}
+
+// Audit is a representation of audit repository
+func (s DBStore) Audit() audit.ADB {
+	ar := audit.NewRepo(s.context(), s.DB)
+	return ar
+}
+
+// Idempotency is a representation of idempotency key repository
+func (s DBStore) Idempotency() idempotency.IDB {
+	ir := idempotency.NewRepo(s.context(), s.DB)
+	return ir
+}
+
+// Counter is a representation of counter repository
+func (s DBStore) Counter() counter.CDB {
+	cr := counter.NewRepo(s.context(), s.DB)
+	return cr
+}
+
+// Outbox is a representation of outbox repository
+func (s DBStore) Outbox() outbox.OB {
+	or := outbox.NewRepo(s.context(), s.DB)
+	return or
+}
+
+// Subscription is a representation of subscription repository
+func (s DBStore) Subscription() subscription.SDB {
+	sr := subscription.NewRepo(s.context(), s.DB)
+	return sr
+}
+
+// Category is a representation of category repository
+func (s DBStore) Category() category.CDB {
+	cr := category.NewRepo(s.context(), s.DB)
+	return cr
+}
+
+// Booking is a representation of slot booking repository
+func (s DBStore) Booking() booking.BDB {
+	br := booking.NewRepo(s.context(), s.DB)
+	return br
+}
@@ -4,8 +4,20 @@ import (
 	"context"
 
 	config "github.com/valensto/api_apbp/configs"
+	"github.com/valensto/api_apbp/infra/repo/audit"
+	"github.com/valensto/api_apbp/infra/repo/booking"
+	"github.com/valensto/api_apbp/infra/repo/category"
+	"github.com/valensto/api_apbp/infra/repo/counter"
+	"github.com/valensto/api_apbp/infra/repo/idempotency"
 	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/infra/repo/outbox"
 	"github.com/valensto/api_apbp/infra/repo/product"
+	"github.com/valensto/api_apbp/infra/repo/purchase"
+	"github.com/valensto/api_apbp/infra/repo/reset"
+	"github.com/valensto/api_apbp/infra/repo/session"
+	"github.com/valensto/api_apbp/infra/repo/stock"
+	"github.com/valensto/api_apbp/infra/repo/subscription"
+	"github.com/valensto/api_apbp/infra/repo/supplier"
 	"github.com/valensto/api_apbp/infra/repo/user"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -30,8 +42,21 @@ type Store interface {
 	Close() error
 
 	BindBD(n string) error
+	WithContext(ctx context.Context) Store
 
 	User() user.UDB
 	Product() product.PDB
 	Order() order.ODB
+	Session() session.SDB
+	Reset() reset.RDB
+	Stock() stock.SDB
+	Supplier() supplier.SDB
+	Purchase() purchase.PDB
+	Audit() audit.ADB
+	Idempotency() idempotency.IDB
+	Counter() counter.CDB
+	Outbox() outbox.OB
+	Subscription() subscription.SDB
+	Category() category.CDB
+	Booking() booking.BDB
 }
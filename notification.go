@@ -0,0 +1,41 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/outbox"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonNotification struct {
+	ID            primitive.ObjectID `json:"-"`
+	Kind          string             `json:"kind"`
+	Resource      string             `json:"resource"`
+	ResourceID    string             `json:"resource_id"`
+	Status        string             `json:"status"`
+	Attempts      int                `json:"attempts"`
+	LastError     string             `json:"last_error,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	NextAttemptAt *time.Time         `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time         `json:"sent_at,omitempty"`
+}
+
+func MapNotificationToJSON(e outbox.Event) JsonNotification {
+	n := JsonNotification{
+		ID:         e.ID,
+		Kind:       e.Kind,
+		Resource:   e.Resource,
+		ResourceID: e.ResourceID.Hex(),
+		Status:     e.Status,
+		Attempts:   e.Attempts,
+		LastError:  e.LastError,
+		CreatedAt:  e.CreatedAt,
+		SentAt:     e.SentAt,
+	}
+
+	if e.Status == outbox.StatusPending {
+		n.NextAttemptAt = &e.NextAttemptAt
+	}
+
+	return n
+}
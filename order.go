@@ -1,7 +1,7 @@
 package api_apbp
 
 import (
-	"fmt"
+	"math"
 	"time"
 
 	"github.com/valensto/api_apbp/infra/repo/order"
@@ -14,17 +14,41 @@ type JsonOrder struct {
 	ID            primitive.ObjectID `json:"-"`
 	CreatedAt     time.Time          `json:"created_at,omitempty"`
 	ModifiedAt    time.Time          `json:"modified_at,omitempty"`
+	DeletedAt     *time.Time         `json:"deleted_at,omitempty"`
 	Ref           string             `json:"ref,omitempty" validate:"required"`
 	RecoveryAt    time.Time          `json:"recovery_at,omitempty"`
 	RelationShip  relationShip       `json:"relationShip,omitempty"`
 	ProductsLines []ProductLine      `json:"products,omitempty" validate:"required,unique,min=1,dive,required"`
-	Status        string             `json:"status,omitempty" validate:"required,oneof=waiting confirm ready delivered"`
+	Status        string             `json:"status,omitempty" validate:"required,oneof=waiting confirm ready delivered cancelled"`
+	StatusHistory []StatusChange     `json:"status_history,omitempty"`
+	Totals        *JsonTotals        `json:"totals,omitempty"`
+}
+
+type JsonTotals struct {
+	Currency string    `json:"currency"`
+	Subtotal int64     `json:"subtotal"`
+	VAT      []JsonVAT `json:"vat"`
+	Total    int64     `json:"total"`
+}
+
+type JsonVAT struct {
+	Rate   float64 `json:"rate"`
+	Base   int64   `json:"base"`
+	Amount int64   `json:"amount"`
+}
+
+type StatusChange struct {
+	From   string             `json:"from"`
+	To     string             `json:"to"`
+	Editor primitive.ObjectID `json:"editor"`
+	At     time.Time          `json:"at"`
 }
 
 type relationShip struct {
-	Customer primitive.ObjectID `json:"customer"`
-	Editor   primitive.ObjectID `json:"editor,omitempty"`
-	Included *included          `json:"included,omitempty"`
+	Customer     primitive.ObjectID  `json:"customer"`
+	Editor       primitive.ObjectID  `json:"editor,omitempty"`
+	Subscription *primitive.ObjectID `json:"subscription,omitempty"`
+	Included     *included           `json:"included,omitempty"`
 }
 
 type included struct {
@@ -33,11 +57,28 @@ type included struct {
 }
 
 type ProductLine struct {
-	Quantity float32 `json:"quantity,omitempty" validate:"required"`
-	Unit     string  `json:"unit,omitempty" validate:"required,oneof=gr p"`
-	Ref      string  `json:"ref,omitempty" validate:"required,ref,len=8"`
-	Name     string  `json:"name,omitempty" validate:"required"`
-	AUW      float32 `json:"auw,omitempty" validate:"required,numeric"`
+	Quantity float32     `json:"quantity,omitempty" validate:"required"`
+	Unit     string      `json:"unit,omitempty" validate:"required,oneof=gr p"`
+	Ref      string      `json:"ref,omitempty" validate:"required,ref,len=8"`
+	Name     string      `json:"name,omitempty" validate:"required"`
+	AUW      float32     `json:"auw,omitempty" validate:"required,numeric"`
+	Price    *JsonPrice  `json:"price,omitempty"`
+	Option   *JsonOption `json:"option,omitempty"`
+	Amount   int64       `json:"amount"`
+
+	Components []JsonLineComponent `json:"components,omitempty"`
+
+	ActualWeight *float32 `json:"actual_weight,omitempty"`
+	Variance     *float64 `json:"variance,omitempty"`
+	VariancePct  *float64 `json:"variance_pct,omitempty"`
+}
+
+type JsonLineComponent struct {
+	Ref      string  `json:"ref"`
+	Name     string  `json:"name"`
+	Quantity float32 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	AUW      float32 `json:"auw"`
 }
 
 type forecastProduct struct {
@@ -48,6 +89,18 @@ type forecastProduct struct {
 type JsonForecast struct {
 	Product  forecastProduct `json:"product"`
 	Quantity int             `json:"quantity"`
+	Net      int             `json:"net"`
+	Grams    float64         `json:"grams"`
+	Pieces   float64         `json:"pieces"`
+}
+
+type JsonForecastBucket struct {
+	Key      string         `json:"key"`
+	Quantity int            `json:"quantity"`
+	Net      int            `json:"net"`
+	Grams    float64        `json:"grams"`
+	Pieces   float64        `json:"pieces"`
+	Products []JsonForecast `json:"products"`
 }
 
 func MapForecastToJson(fo order.Forecast) JsonForecast {
@@ -59,6 +112,25 @@ func MapForecastToJson(fo order.Forecast) JsonForecast {
 	return JsonForecast{
 		Product:  fp,
 		Quantity: fo.Quantity,
+		Net:      fo.Net,
+		Grams:    fo.Grams,
+		Pieces:   fo.Pieces,
+	}
+}
+
+func MapForecastBucketToJson(fb order.ForecastBucket) JsonForecastBucket {
+	products := make([]JsonForecast, len(fb.Products))
+	for i, fo := range fb.Products {
+		products[i] = MapForecastToJson(fo)
+	}
+
+	return JsonForecastBucket{
+		Key:      fb.Key,
+		Quantity: fb.Quantity,
+		Net:      fb.Net,
+		Grams:    fb.Grams,
+		Pieces:   fb.Pieces,
+		Products: products,
 	}
 }
 
@@ -81,6 +153,33 @@ func MapOrderToJSON(o order.Order) JsonOrder {
 			Ref:      pl.Ref,
 			Name:     pl.Name,
 			AUW:      pl.AUW,
+			Price:    MapPriceToJSON(pl.Price),
+			Option:   MapOptionToJSON(pl.Option),
+			Amount:   pl.Amount(),
+
+			Components:   mapLineComponentsToJSON(pl.Components),
+			ActualWeight: pl.ActualWeight,
+			Variance:     pl.Variance(),
+		}
+		if v := pl.Variance(); v != nil && pl.Grams() > 0 {
+			pct := math.Round(*v/pl.Grams()*10000) / 100
+			productLines[i].VariancePct = &pct
+		}
+	}
+
+	totals := o.Totals
+	if totals == nil {
+		t := order.ComputeTotals(o.ProductsLines)
+		totals = &t
+	}
+
+	history := make([]StatusChange, len(o.StatusHistory))
+	for i, sc := range o.StatusHistory {
+		history[i] = StatusChange{
+			From:   sc.From,
+			To:     sc.To,
+			Editor: sc.Editor,
+			At:     sc.At,
 		}
 	}
 
@@ -88,14 +187,36 @@ func MapOrderToJSON(o order.Order) JsonOrder {
 		Ref:        o.Ref,
 		CreatedAt:  o.CreatedAt,
 		ModifiedAt: o.ModifiedAt,
+		DeletedAt:  o.DeletedAt,
 		RecoveryAt: o.RecoveryAt,
 		RelationShip: relationShip{
-			Customer: o.RelationShip.Customer,
-			Editor:   o.RelationShip.Editor,
-			Included: MapIncludeToJSON(o.RelationShip.Included),
+			Customer:     o.RelationShip.Customer,
+			Editor:       o.RelationShip.Editor,
+			Subscription: o.RelationShip.Subscription,
+			Included:     MapIncludeToJSON(o.RelationShip.Included),
 		},
 		ProductsLines: productLines,
 		Status:        o.Status,
+		StatusHistory: history,
+		Totals:        MapTotalsToJSON(*totals),
+	}
+}
+
+func MapTotalsToJSON(t order.Totals) *JsonTotals {
+	vat := make([]JsonVAT, len(t.VAT))
+	for i, v := range t.VAT {
+		vat[i] = JsonVAT{
+			Rate:   v.Rate,
+			Base:   v.Base,
+			Amount: v.Amount,
+		}
+	}
+
+	return &JsonTotals{
+		Currency: t.Currency,
+		Subtotal: t.Subtotal,
+		VAT:      vat,
+		Total:    t.Total,
 	}
 }
 
@@ -126,7 +247,20 @@ func (o JsonOrder) NewStatusMail() mailer.Mail {
 	return mail
 }
 
-func GenerateRef() string {
-	now := time.Now()
-	return fmt.Sprintf("%s", now.Format("060201150405"))
+func mapLineComponentsToJSON(cs []order.Component) []JsonLineComponent {
+	if len(cs) == 0 {
+		return nil
+	}
+
+	components := make([]JsonLineComponent, len(cs))
+	for i, c := range cs {
+		components[i] = JsonLineComponent{
+			Ref:      c.Ref,
+			Name:     c.Name,
+			Quantity: c.Quantity,
+			Unit:     c.Unit,
+			AUW:      c.AUW,
+		}
+	}
+	return components
 }
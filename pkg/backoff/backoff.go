@@ -0,0 +1,24 @@
+package backoff
+
+import "time"
+
+// Exponential return the delay to wait after the nth failed attempt, base doubled
+// for each attempt after the first and capped to max
+func Exponential(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= max || d <= 0 {
+			return max
+		}
+	}
+
+	if d > max {
+		return max
+	}
+	return d
+}
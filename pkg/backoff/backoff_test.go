@@ -0,0 +1,31 @@
+package backoff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/backoff"
+)
+
+func TestExponential(t *testing.T) {
+	var tests = []struct {
+		attempt  int
+		base     time.Duration
+		max      time.Duration
+		expected time.Duration
+	}{
+		{0, time.Minute, time.Hour, time.Minute},
+		{1, time.Minute, time.Hour, time.Minute},
+		{2, time.Minute, time.Hour, 2 * time.Minute},
+		{4, time.Minute, time.Hour, 8 * time.Minute},
+		{7, time.Minute, time.Hour, time.Hour},
+		{100, time.Minute, time.Hour, time.Hour},
+		{1, 2 * time.Hour, time.Hour, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if d := backoff.Exponential(tt.attempt, tt.base, tt.max); d != tt.expected {
+			t.Errorf("Exponential on attempt %v, expected: %v, got: %v", tt.attempt, tt.expected, d)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is returned when an entity tag is not a quoted version
+var ErrInvalid = errors.New("entity tag must be a quoted version")
+
+// Format return the strong entity tag of a document version
+func Format(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// Parse return the version of an entity tag, weak tags are accepted
+func Parse(tag string) (int, error) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, ErrInvalid
+	}
+
+	v, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || v < 0 {
+		return 0, ErrInvalid
+	}
+	return v, nil
+}
@@ -0,0 +1,51 @@
+package etag_test
+
+import (
+	"testing"
+
+	"github.com/valensto/api_apbp/pkg/etag"
+)
+
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		in       int
+		expected string
+	}{
+		{0, `"0"`},
+		{12, `"12"`},
+	}
+
+	for _, tt := range tests {
+		if tag := etag.Format(tt.in); tag != tt.expected {
+			t.Errorf("Format on %v, expected: %v, got: %v", tt.in, tt.expected, tag)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected int
+		err      bool
+	}{
+		{`"3"`, 3, false},
+		{` W/"42" `, 42, false},
+		{`"0"`, 0, false},
+		{`3`, 0, true},
+		{`"-1"`, 0, true},
+		{`"abc"`, 0, true},
+		{`"`, 0, true},
+		{`"1", "2"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		v, err := etag.Parse(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse on %v, expected error: %v, got: %v", tt.in, tt.err, err)
+			continue
+		}
+		if !tt.err && v != tt.expected {
+			t.Errorf("Parse on %v, expected: %v, got: %v", tt.in, tt.expected, v)
+		}
+	}
+}
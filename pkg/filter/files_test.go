@@ -3,6 +3,7 @@ package filter_test
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/valensto/api_apbp/pkg/filter"
 )
@@ -17,6 +18,10 @@ func TestParseQuery(t *testing.T) {
 		{"/v1/orders?populate=1", filter.Query{
 			Populate: true,
 		}},
+		{"/v1/orders?with_deleted=true", filter.Query{
+			WithDeleted: true,
+		}},
+		{"/v1/orders?with_deleted=nope", filter.Query{}},
 	}
 
 	for _, tt := range tests {
@@ -24,8 +29,120 @@ func TestParseQuery(t *testing.T) {
 		if f.Populate != tt.expected.Populate {
 			t.Errorf("ParseQuery failed to populate, expected: %v, got: %v", tt.expected.Populate, f.Populate)
 		}
+		if f.WithDeleted != tt.expected.WithDeleted {
+			t.Errorf("ParseQuery failed to parse with_deleted, expected: %v, got: %v", tt.expected.WithDeleted, f.WithDeleted)
+		}
 		if !reflect.DeepEqual(&f.Sort, &tt.expected.Sort) {
 			t.Errorf("ParseQuery failed to sort, expected: %v, got: %v", tt.expected.Sort, f.Sort)
 		}
 	}
 }
+
+func TestParseQueryFilters(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected []filter.Condition
+	}{
+		{"/v1/orders", nil},
+		{"/v1/orders?filter[status]=ready", []filter.Condition{
+			{Field: "status", Op: "eq", Value: "ready"},
+		}},
+		{"/v1/orders?filter[recovery_at][lte]=2020-10-10&filter[recovery_at][gte]=2020-10-01&filter[status][in]=ready,confirm", []filter.Condition{
+			{Field: "recovery_at", Op: "gte", Value: "2020-10-01"},
+			{Field: "recovery_at", Op: "lte", Value: "2020-10-10"},
+			{Field: "status", Op: "in", Value: "ready,confirm"},
+		}},
+		{"/v1/orders?filter[totals.total][gt]=1000&filters[status]=ready&filter=ready", []filter.Condition{
+			{Field: "totals.total", Op: "gt", Value: "1000"},
+		}},
+	}
+
+	for _, tt := range tests {
+		f := filter.ParseQuery(tt.in)
+		if !reflect.DeepEqual(f.Filters, tt.expected) {
+			t.Errorf("ParseQuery failed to filter on %v, expected: %v, got: %v", tt.in, tt.expected, f.Filters)
+		}
+	}
+}
+
+func TestParseQuerySort(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected []filter.SortField
+	}{
+		{"/v1/orders", nil},
+		{"/v1/orders?sort=ref", []filter.SortField{{Field: "ref"}}},
+		{"/v1/orders?sort=-recovery_at,ref", []filter.SortField{
+			{Field: "recovery_at", Desc: true},
+			{Field: "ref"},
+		}},
+		{"/v1/orders?sort=-recovery_at,,%20ref", []filter.SortField{
+			{Field: "recovery_at", Desc: true},
+			{Field: "ref"},
+		}},
+	}
+
+	for _, tt := range tests {
+		f := filter.ParseQuery(tt.in)
+		if !reflect.DeepEqual(f.Sort, tt.expected) {
+			t.Errorf("ParseQuery failed to sort on %v, expected: %v, got: %v", tt.in, tt.expected, f.Sort)
+		}
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	schema := filter.Schema{
+		"status":      {Kind: filter.String},
+		"recovery_at": {Kind: filter.Date, Sortable: true},
+	}
+
+	var tests = []struct {
+		in  string
+		err bool
+	}{
+		{"/v1/orders?filter[status]=ready&sort=-recovery_at", false},
+		{"/v1/orders?filter[recovery_at][gte]=2020-10-01", false},
+		{"/v1/orders?filter[password]=secret", true},
+		{"/v1/orders?filter[status][like]=rea", true},
+		{"/v1/orders?sort=status", true},
+		{"/v1/orders?sort=unknown", true},
+	}
+
+	for _, tt := range tests {
+		err := schema.Check(filter.ParseQuery(tt.in))
+		if (err != nil) != tt.err {
+			t.Errorf("Check on %v, expected error: %v, got: %v", tt.in, tt.err, err)
+		}
+	}
+}
+
+func TestFieldParse(t *testing.T) {
+	var tests = []struct {
+		field    filter.Field
+		op       string
+		in       string
+		expected interface{}
+		err      bool
+	}{
+		{filter.Field{Kind: filter.String}, "eq", "ready", "ready", false},
+		{filter.Field{Kind: filter.Number}, "gt", "12.5", 12.5, false},
+		{filter.Field{Kind: filter.Number}, "gt", "twelve", nil, true},
+		{filter.Field{Kind: filter.Bool}, "eq", "true", true, false},
+		{filter.Field{Kind: filter.Date}, "gte", "2020-10-01", time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC), false},
+		{filter.Field{Kind: filter.Date}, "gte", "2020-10-01T08:30:00Z", time.Date(2020, 10, 1, 8, 30, 0, 0, time.UTC), false},
+		{filter.Field{Kind: filter.Date}, "gte", "01/10/2020", nil, true},
+		{filter.Field{Kind: filter.String}, "in", "ready,confirm", []interface{}{"ready", "confirm"}, false},
+		{filter.Field{Kind: filter.Number}, "nin", "1,x", nil, true},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.field.Parse(tt.op, tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse on %v, expected error: %v, got: %v", tt.in, tt.err, err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(v, tt.expected) {
+			t.Errorf("Parse on %v, expected: %v, got: %v", tt.in, tt.expected, v)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package filter
 
 import (
 	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/valensto/api_apbp/pkg/pagination"
@@ -13,13 +16,27 @@ type Range struct {
 	End   time.Time
 }
 
+// Condition is a filter on a field value, ?filter[field][op]=value
+type Condition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// SortField is a sort key, ?sort=-field sorts descending
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
 type Query struct {
-	Populate   bool
-	Filters    *map[string]string
-	Sort       *map[string]string
-	Range      *Range
-	Term       string
-	Pagination pagination.Query
+	Populate    bool
+	WithDeleted bool
+	Filters     []Condition
+	Sort        []SortField
+	Range       *Range
+	Term        string
+	Pagination  pagination.Query
 }
 
 func (q *Query) parseRange(query url.Values) {
@@ -44,6 +61,45 @@ func (q *Query) parseRange(query url.Values) {
 	q.Range = &r
 }
 
+var filterKey = regexp.MustCompile(`^filter\[([\w.]+)\](?:\[(\w+)\])?$`)
+
+func (q *Query) parseFilters(query url.Values) {
+	for k, vs := range query {
+		m := filterKey.FindStringSubmatch(k)
+		if m == nil {
+			continue
+		}
+
+		op := m[2]
+		if op == "" {
+			op = OpEq
+		}
+
+		for _, v := range vs {
+			q.Filters = append(q.Filters, Condition{Field: m[1], Op: op, Value: v})
+		}
+	}
+
+	sort.Slice(q.Filters, func(i, j int) bool {
+		if q.Filters[i].Field != q.Filters[j].Field {
+			return q.Filters[i].Field < q.Filters[j].Field
+		}
+		return q.Filters[i].Op < q.Filters[j].Op
+	})
+}
+
+func (q *Query) parseSort(query url.Values) {
+	for _, k := range strings.Split(query.Get("sort"), ",") {
+		k = strings.TrimSpace(k)
+		desc := strings.HasPrefix(k, "-")
+		k = strings.TrimLeft(k, "-+")
+		if k == "" {
+			continue
+		}
+		q.Sort = append(q.Sort, SortField{Field: k, Desc: desc})
+	}
+}
+
 func ParseQuery(queryStr string) Query {
 	f := &Query{}
 
@@ -62,27 +118,20 @@ func ParseQuery(queryStr string) Query {
 		}
 	}
 
+	if deletedStr := query.Get("with_deleted"); deletedStr != "" {
+		if withDeleted, err := strconv.ParseBool(deletedStr); err == nil {
+			f.WithDeleted = withDeleted
+		}
+	}
+
 	if termStr := query.Get("term"); termStr != "" {
 		f.Term = termStr
 	}
 
 	f.parseRange(query)
 
-	// if sorts, present := query["sort"]; present || len(sorts) > 0 {
-	// 	m := make(map[string]string)
-
-	// 	jsonSort, err := json.Marshal(sorts)
-	// 	if err != nil {
-	// 		fmt.Printf("error occured during marshalling, got=%w", err)
-	// 	}
-	// 	fmt.Println(jsonSort)
-	// 	err = json.Unmarshal(jsonSort, &m)
-	// 	if err != nil {
-	// 		fmt.Printf("error occured during unmarshalling, got=%w", err)
-	// 	}
-	// 	fmt.Println(m)
-	// 	f.Sort = &m
-	// }
+	f.parseFilters(query)
+	f.parseSort(query)
 
 	return *f
 }
@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter operators
+const (
+	OpEq  = "eq"
+	OpNe  = "ne"
+	OpGt  = "gt"
+	OpGte = "gte"
+	OpLt  = "lt"
+	OpLte = "lte"
+	OpIn  = "in"
+	OpNin = "nin"
+)
+
+// Operators lists every known filter operator
+var Operators = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn, OpNin}
+
+// Kind is the type of a field value
+type Kind int
+
+// Field kinds, ID values are left as strings for the repository to convert
+const (
+	String Kind = iota
+	Number
+	Date
+	Bool
+	ID
+)
+
+// Field describes how a field can be filtered and sorted
+type Field struct {
+	Kind     Kind
+	Sortable bool
+}
+
+// Schema is the whitelist of fields a repository can be filtered and sorted by
+type Schema map[string]Field
+
+// Check return an error for the first filter or sort the schema does not allow
+func (s Schema) Check(q Query) error {
+	for _, c := range q.Filters {
+		if _, ok := s[c.Field]; !ok {
+			return fmt.Errorf("cannot filter on unknown field %v", c.Field)
+		}
+		if !isOperator(c.Op) {
+			return fmt.Errorf("unknown operator %v on %v, must be one of %v", c.Op, c.Field, Operators)
+		}
+	}
+
+	for _, sf := range q.Sort {
+		if f, ok := s[sf.Field]; !ok || !f.Sortable {
+			return fmt.Errorf("cannot sort on field %v", sf.Field)
+		}
+	}
+
+	return nil
+}
+
+// Parse convert a raw filter value to the field kind, in and nin take a comma separated list
+func (f Field) Parse(op, raw string) (interface{}, error) {
+	if op != OpIn && op != OpNin {
+		return f.parse(raw)
+	}
+
+	var vs []interface{}
+	for _, r := range strings.Split(raw, ",") {
+		v, err := f.parse(r)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+func (f Field) parse(raw string) (interface{}, error) {
+	switch f.Kind {
+	case Number:
+		return strconv.ParseFloat(raw, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Date:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot parse date %q, use 2006-01-02T15:04:05Z or 2006-01-02", raw)
+	}
+	return raw, nil
+}
+
+func isOperator(op string) bool {
+	for _, o := range Operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
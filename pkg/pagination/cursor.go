@@ -0,0 +1,112 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Cursor is the opaque position of a document in a keyset paginated list,
+// value is the sort key of the document formatted as a string
+type Cursor struct {
+	ID    string `json:"id"`
+	Value string `json:"v,omitempty"`
+	Prev  bool   `json:"p,omitempty"`
+}
+
+// Encode return the cursor as an url safe string
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parse a cursor encoded by Encode
+func DecodeCursor(s string) (Cursor, error) {
+	c := Cursor{}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("cursor is malformed. got=%w", err)
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("cursor is malformed. got=%w", err)
+	}
+
+	if c.ID == "" {
+		return c, fmt.Errorf("cursor is malformed, it has no id")
+	}
+
+	return c, nil
+}
+
+// keyset is the meta of a keyset paginated page, back is true when the page was reached with a prev cursor,
+// from is true when it was reached with a cursor at all
+type keyset struct {
+	More  bool   `json:"more"`
+	Back  bool   `json:"back"`
+	From  bool   `json:"from"`
+	First Cursor `json:"first"`
+	Last  Cursor `json:"last"`
+}
+
+// cursors return next and prev cursors of the page, empty when there is nothing on that side
+func (k keyset) cursors() (string, string) {
+	var next, prev string
+
+	first := k.First
+	first.Prev = true
+
+	if k.Back {
+		next = k.Last.Encode()
+		if k.More {
+			prev = first.Encode()
+		}
+		return next, prev
+	}
+
+	if k.More {
+		next = k.Last.Encode()
+	}
+	if k.From {
+		prev = first.Encode()
+	}
+	return next, prev
+}
+
+// Links return pagination links of the page, cursor ones in keyset mode
+func (q Query) Links(base string, meta Meta) map[string]string {
+	if !q.Keyset {
+		return q.GetLinks(base, meta.TotalElements)
+	}
+
+	links := make(map[string]string)
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return links
+	}
+
+	link := func(cursor string) string {
+		query := u.Query()
+		query.Set("limit", strconv.Itoa(q.Limit))
+		query.Set("cursor", cursor)
+		query.Del("page")
+		l := *u
+		l.RawQuery = query.Encode()
+		return l.String()
+	}
+
+	links["self"] = link(q.Cursor)
+	links["first"] = link("")
+	if meta.Next != "" {
+		links["next"] = link(meta.Next)
+	}
+	if meta.Prev != "" {
+		links["prev"] = link(meta.Prev)
+	}
+
+	return links
+}
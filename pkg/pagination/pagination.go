@@ -12,6 +12,10 @@ type Meta struct {
 	PerPages      int `json:"perPages"`
 	TotalElements int `json:"totalElements"`
 	TotalPages    int `json:"totalPages"`
+
+	// Next and Prev are the cursors around a keyset paginated page
+	Next string `json:"-"`
+	Prev string `json:"-"`
 }
 
 func NewMeta(m map[string]interface{}) (Meta, error) {
@@ -26,13 +30,26 @@ func NewMeta(m map[string]interface{}) (Meta, error) {
 		return meta, err
 	}
 
+	ks := struct {
+		Keyset *keyset `json:"keyset"`
+	}{}
+	if err = json.Unmarshal(data, &ks); err != nil {
+		return meta, err
+	}
+	if ks.Keyset != nil {
+		meta.Next, meta.Prev = ks.Keyset.cursors()
+	}
+
 	return meta, nil
 }
 
 // Query has pagination query parameters.
+// Keyset is set by a cursor param, even empty, and replaces page by the cursor.
 type Query struct {
-	Limit int
-	Skip  int
+	Limit  int
+	Skip   int
+	Keyset bool
+	Cursor string
 }
 
 func (q *Query) init() {
@@ -103,7 +120,12 @@ func ParseQuery(queryStr string) Query {
 		}
 	}
 
-	if pageStr := query.Get("page"); pageStr != "" {
+	if cursor, ok := query["cursor"]; ok {
+		p.Keyset = true
+		p.Cursor = cursor[0]
+	}
+
+	if pageStr := query.Get("page"); pageStr != "" && !p.Keyset {
 		if page, err := strconv.Atoi(pageStr); err == nil {
 			p.Skip = p.pageToSkip(page)
 		}
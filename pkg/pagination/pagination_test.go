@@ -146,3 +146,137 @@ func TestGetLinks(t *testing.T) {
 		}
 	}
 }
+
+func TestParseQueryCursor(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected pagination.Query
+	}{
+		{"/v1/orders?limit=20&page=2", pagination.Query{Limit: 20, Skip: 20}},
+		{"/v1/orders?limit=20&cursor=", pagination.Query{Limit: 20, Keyset: true}},
+		{"/v1/orders?limit=20&page=2&cursor=abc", pagination.Query{Limit: 20, Keyset: true, Cursor: "abc"}},
+	}
+
+	for _, tt := range tests {
+		p := pagination.ParseQuery(tt.in)
+		if p != tt.expected {
+			t.Errorf("ParseQuery failed on %v, expected: %+v, got: %+v", tt.in, tt.expected, p)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	var tests = []struct {
+		in  pagination.Cursor
+		err bool
+	}{
+		{pagination.Cursor{ID: "5f7f1c2b9d3e2a0001a1b2c3"}, false},
+		{pagination.Cursor{ID: "5f7f1c2b9d3e2a0001a1b2c3", Value: "2020-10-09T08:00:00.000Z", Prev: true}, false},
+	}
+
+	for _, tt := range tests {
+		c, err := pagination.DecodeCursor(tt.in.Encode())
+		if err != nil {
+			t.Errorf("DecodeCursor failed on %+v, got: %v", tt.in, err)
+			continue
+		}
+		if c != tt.in {
+			t.Errorf("DecodeCursor failed, expected: %+v, got: %+v", tt.in, c)
+		}
+	}
+
+	for _, in := range []string{"not a cursor", "e30"} {
+		if _, err := pagination.DecodeCursor(in); err == nil {
+			t.Errorf("DecodeCursor expected an error on %v", in)
+		}
+	}
+}
+
+func TestNewMetaCursors(t *testing.T) {
+	first := pagination.Cursor{ID: "a", Value: "1"}
+	last := pagination.Cursor{ID: "b", Value: "2"}
+	prev := pagination.Cursor{ID: "a", Value: "1", Prev: true}
+
+	keyset := func(more, back, from bool) map[string]interface{} {
+		return map[string]interface{}{
+			"perPages": 10,
+			"keyset": map[string]interface{}{
+				"more":  more,
+				"back":  back,
+				"from":  from,
+				"first": map[string]interface{}{"id": first.ID, "v": first.Value},
+				"last":  map[string]interface{}{"id": last.ID, "v": last.Value},
+			},
+		}
+	}
+
+	var tests = []struct {
+		name string
+		in   map[string]interface{}
+		next string
+		prev string
+	}{
+		{"first page", keyset(true, false, false), last.Encode(), ""},
+		{"only page", keyset(false, false, false), "", ""},
+		{"middle page forward", keyset(true, false, true), last.Encode(), prev.Encode()},
+		{"last page forward", keyset(false, false, true), "", prev.Encode()},
+		{"middle page backward", keyset(true, true, true), last.Encode(), prev.Encode()},
+		{"first page backward", keyset(false, true, true), last.Encode(), ""},
+		{"offset page", map[string]interface{}{"perPages": 10}, "", ""},
+	}
+
+	for _, tt := range tests {
+		meta, err := pagination.NewMeta(tt.in)
+		if err != nil {
+			t.Errorf("NewMeta failed on %v, got: %v", tt.name, err)
+			continue
+		}
+		if meta.Next != tt.next {
+			t.Errorf("NewMeta failed on %v next, expected: %v, got: %v", tt.name, tt.next, meta.Next)
+		}
+		if meta.Prev != tt.prev {
+			t.Errorf("NewMeta failed on %v prev, expected: %v, got: %v", tt.name, tt.prev, meta.Prev)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	var tests = []struct {
+		query    pagination.Query
+		base     string
+		meta     pagination.Meta
+		expected map[string]string
+	}{
+		{pagination.Query{Limit: 10, Skip: 10}, "http://localhost/users", pagination.Meta{TotalElements: 40}, map[string]string{
+			"self":  "http://localhost/users?limit=10&page=2",
+			"first": "http://localhost/users?limit=10&page=1",
+			"prev":  "http://localhost/users?limit=10&page=1",
+			"next":  "http://localhost/users?limit=10&page=3",
+			"last":  "http://localhost/users?limit=10&page=4",
+		}},
+		{pagination.Query{Limit: 10, Keyset: true}, "http://localhost/users?cursor=&sort=-name", pagination.Meta{Next: "nx"}, map[string]string{
+			"self":  "http://localhost/users?cursor=&limit=10&sort=-name",
+			"first": "http://localhost/users?cursor=&limit=10&sort=-name",
+			"next":  "http://localhost/users?cursor=nx&limit=10&sort=-name",
+		}},
+		{pagination.Query{Limit: 5, Keyset: true, Cursor: "cur"}, "http://localhost/users?cursor=cur&limit=5", pagination.Meta{Next: "nx", Prev: "pv"}, map[string]string{
+			"self":  "http://localhost/users?cursor=cur&limit=5",
+			"first": "http://localhost/users?cursor=&limit=5",
+			"next":  "http://localhost/users?cursor=nx&limit=5",
+			"prev":  "http://localhost/users?cursor=pv&limit=5",
+		}},
+	}
+
+	for _, tt := range tests {
+		links := tt.query.Links(tt.base, tt.meta)
+
+		if len(links) != len(tt.expected) {
+			t.Errorf("Links failed on %v, expected: %v, got: %v", tt.base, tt.expected, links)
+		}
+		for k, v := range tt.expected {
+			if links[k] != v {
+				t.Errorf("Links failed to %v on %v, expected: %v, got: %v", k, tt.base, v, links[k])
+			}
+		}
+	}
+}
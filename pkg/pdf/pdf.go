@@ -0,0 +1,157 @@
+// Package pdf writes simple PDF documents made of text, lines and rectangles
+// using the standard Helvetica fonts, so no font file needs to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Fonts available to Text, they are built in every PDF reader
+const (
+	Regular = "F1"
+	Bold    = "F2"
+)
+
+var fonts = map[string]string{
+	Regular: "Helvetica",
+	Bold:    "Helvetica-Bold",
+}
+
+// Document is a PDF under construction, coordinates are in points from the bottom left corner
+type Document struct {
+	width  float64
+	height float64
+	pages  []*bytes.Buffer
+}
+
+// New return an empty document of the given page size
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// AddPage start a new page, following drawings go on it
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, new(bytes.Buffer))
+}
+
+// Pages return the number of pages
+func (d *Document) Pages() int {
+	return len(d.pages)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draw s with its baseline starting at x, y
+func (d *Document) Text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(y), Escape(s))
+}
+
+// Line draw a line from x1, y1 to x2, y2
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "%s %s m %s %s l S\n", num(x1), num(y1), num(x2), num(y2))
+}
+
+// Rect draw the outline of a rectangle with its bottom left corner at x, y
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "%s %s %s %s re S\n", num(x), num(y), num(w), num(h))
+}
+
+// WriteTo write the document, an empty document gets one blank page
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// objects 1 catalog, 2 pages, 3 and 4 fonts, then a page and its content for each page
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	for _, f := range []string{Regular, Bold} {
+		obj(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fonts[f]))
+	}
+
+	for i, p := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(d.width), num(d.height), 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// winAnsi maps the runes WinAnsiEncoding places outside of Latin-1
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, 'Œ': 0x8c, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '–': 0x96, '—': 0x97, 'œ': 0x9c,
+}
+
+// Escape encode s as the content of a PDF literal string in WinAnsiEncoding,
+// runes it cannot encode are replaced by a question mark
+func Escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if c, ok := winAnsi[r]; ok {
+			fmt.Fprintf(&b, "\\%03o", c)
+			continue
+		}
+
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20 || r > 0xff || (r >= 0x80 && r < 0xa0):
+			b.WriteByte('?')
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			fmt.Fprintf(&b, "\\%03o", r)
+		}
+	}
+	return b.String()
+}
+
+// num format a coordinate without useless decimals
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,95 @@
+package pdf_test
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/valensto/api_apbp/pkg/pdf"
+)
+
+func TestEscape(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected string
+	}{
+		{"Carottes", "Carottes"},
+		{"(500 gr)", `\(500 gr\)`},
+		{`a\b`, `a\\b`},
+		{"Crème", `Cr\350me`},
+		{"bœuf à 12€", `b\234uf \340 12\200`},
+		{"ligne\nsuivante", "ligne suivante"},
+		{"poids ≈ 1kg", "poids ? 1kg"},
+	}
+
+	for _, tt := range tests {
+		if s := pdf.Escape(tt.in); s != tt.expected {
+			t.Errorf("Escape on %q, expected: %v, got: %v", tt.in, tt.expected, s)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	var tests = []struct {
+		pages    int
+		expected int
+	}{
+		{0, 1},
+		{1, 1},
+		{3, 3},
+	}
+
+	for _, tt := range tests {
+		d := pdf.New(pdf.A4Width, pdf.A4Height)
+		for i := 0; i < tt.pages; i++ {
+			d.AddPage()
+			d.Text(40, 800, pdf.Bold, 14, "Préparation (page)")
+			d.Rect(40, 780, 10, 10)
+			d.Line(40, 770, 555, 770)
+		}
+
+		var buf bytes.Buffer
+		if _, err := d.WriteTo(&buf); err != nil {
+			t.Fatalf("WriteTo on %v pages, got err: %v", tt.pages, err)
+		}
+		out := buf.Bytes()
+
+		if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+			t.Errorf("WriteTo on %v pages, missing header or trailer", tt.pages)
+		}
+
+		if n := bytes.Count(out, []byte("/Type /Page ")); n != tt.expected {
+			t.Errorf("WriteTo on %v pages, expected: %v pages, got: %v", tt.pages, tt.expected, n)
+		}
+
+		checkXref(t, out)
+	}
+}
+
+var objHeader = regexp.MustCompile(`^(\d+) 0 obj`)
+
+// checkXref verify every xref entry points at the header of its object
+func checkXref(t *testing.T, out []byte) {
+	s := string(out)
+	i := strings.LastIndex(s, "startxref\n")
+	xref, err := strconv.Atoi(strings.Fields(s[i+len("startxref\n"):])[0])
+	if err != nil {
+		t.Fatalf("cannot read startxref. got err: %v", err)
+	}
+
+	if !strings.HasPrefix(s[xref:], "xref\n") {
+		t.Fatalf("startxref %v does not point to the xref table", xref)
+	}
+
+	lines := strings.Split(s[xref:], "\n")
+	size, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for n := 1; n < size; n++ {
+		off, _ := strconv.Atoi(strings.Fields(lines[2+n])[0])
+		m := objHeader.FindStringSubmatch(s[off:])
+		if m == nil || m[1] != strconv.Itoa(n) {
+			t.Errorf("xref entry %v at offset %v does not point to its object", n, off)
+		}
+	}
+}
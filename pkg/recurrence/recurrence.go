@@ -0,0 +1,91 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Frequencies of a rule
+const (
+	Weekly   = "weekly"
+	Biweekly = "biweekly"
+)
+
+// Frequencies lists valid rule frequencies
+var Frequencies = []string{Weekly, Biweekly}
+
+// ErrInvalid is returned by Validate on malformed rules
+var ErrInvalid = errors.New("invalid recurrence rule")
+
+// Rule repeats a time of day on a weekday every week or every other week.
+// Biweekly rules count weeks from the first occurrence on or after Start.
+type Rule struct {
+	Frequency string
+	Weekday   time.Weekday
+	At        string
+	Start     time.Time
+}
+
+// Validate return an error wrapping ErrInvalid when the rule cannot produce occurrences
+func (r Rule) Validate() error {
+	if r.Frequency != Weekly && r.Frequency != Biweekly {
+		return fmt.Errorf("%w: frequency must be one of %v. got=%v", ErrInvalid, Frequencies, r.Frequency)
+	}
+	if r.Weekday < time.Sunday || r.Weekday > time.Saturday {
+		return fmt.Errorf("%w: weekday must be between 0 and 6. got=%d", ErrInvalid, r.Weekday)
+	}
+	if _, err := time.Parse("15:04", r.At); err != nil {
+		return fmt.Errorf("%w: at must be formatted as 15:04. got=%v", ErrInvalid, r.At)
+	}
+	return nil
+}
+
+// step return the days between two occurrences
+func (r Rule) step() int {
+	if r.Frequency == Biweekly {
+		return 14
+	}
+	return 7
+}
+
+// first return the day of the first occurrence, in loc
+func (r Rule) first(loc *time.Location) time.Time {
+	s := r.Start.In(loc)
+	day := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, loc)
+	return day.AddDate(0, 0, (int(r.Weekday)-int(day.Weekday())+7)%7)
+}
+
+// Between return occurrences after from and up to to, in the location of from.
+// Occurrences falling on the day of a pause are skipped.
+func (r Rule) Between(from, to time.Time, pauses []time.Time) []time.Time {
+	var ts []time.Time
+	if r.Validate() != nil || !to.After(from) {
+		return ts
+	}
+
+	loc := from.Location()
+	at, _ := time.Parse("15:04", r.At)
+
+	paused := make(map[string]bool, len(pauses))
+	for _, p := range pauses {
+		paused[p.In(loc).Format("2006-01-02")] = true
+	}
+
+	day := r.first(loc)
+	// jump close to from without losing the biweekly parity
+	if from.After(day) {
+		weeks := int(from.Sub(day).Hours()/24) / r.step()
+		day = day.AddDate(0, 0, weeks*r.step())
+	}
+
+	for ; ; day = day.AddDate(0, 0, r.step()) {
+		t := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+		if t.After(to) {
+			return ts
+		}
+		if t.After(from) && !paused[day.Format("2006-01-02")] {
+			ts = append(ts, t)
+		}
+	}
+}
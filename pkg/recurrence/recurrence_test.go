@@ -0,0 +1,96 @@
+package recurrence_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/recurrence"
+)
+
+func date(day, hm string) time.Time {
+	t, _ := time.Parse("2006-01-02 15:04", day+" "+hm)
+	return t
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		in  recurrence.Rule
+		err bool
+	}{
+		{recurrence.Rule{Frequency: recurrence.Weekly, Weekday: time.Friday, At: "09:30"}, false},
+		{recurrence.Rule{Frequency: recurrence.Biweekly, Weekday: time.Sunday, At: "18:00"}, false},
+		{recurrence.Rule{Frequency: "monthly", Weekday: time.Friday, At: "09:30"}, true},
+		{recurrence.Rule{Frequency: recurrence.Weekly, Weekday: 7, At: "09:30"}, true},
+		{recurrence.Rule{Frequency: recurrence.Weekly, Weekday: time.Friday, At: "9h30"}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.in.Validate()
+		if (err != nil) != tt.err || (err != nil && !errors.Is(err, recurrence.ErrInvalid)) {
+			t.Errorf("Validate on %+v, expected err: %v, got: %v", tt.in, tt.err, err)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	// 2020-10-09 is a friday
+	weekly := recurrence.Rule{Frequency: recurrence.Weekly, Weekday: time.Friday, At: "09:30", Start: date("2020-10-01", "00:00")}
+	biweekly := recurrence.Rule{Frequency: recurrence.Biweekly, Weekday: time.Friday, At: "09:30", Start: date("2020-10-01", "00:00")}
+
+	var tests = []struct {
+		name     string
+		rule     recurrence.Rule
+		from     time.Time
+		to       time.Time
+		pauses   []time.Time
+		expected []time.Time
+	}{
+		{
+			"weekly", weekly, date("2020-10-05", "00:00"), date("2020-10-31", "00:00"), nil,
+			[]time.Time{date("2020-10-09", "09:30"), date("2020-10-16", "09:30"), date("2020-10-23", "09:30"), date("2020-10-30", "09:30")},
+		},
+		{
+			"weekly from excluded", weekly, date("2020-10-09", "09:30"), date("2020-10-17", "00:00"), nil,
+			[]time.Time{date("2020-10-16", "09:30")},
+		},
+		{
+			"weekly to included", weekly, date("2020-10-10", "00:00"), date("2020-10-16", "09:30"), nil,
+			[]time.Time{date("2020-10-16", "09:30")},
+		},
+		{
+			"not started", weekly, date("2020-09-01", "00:00"), date("2020-10-10", "00:00"), nil,
+			[]time.Time{date("2020-10-02", "09:30"), date("2020-10-09", "09:30")},
+		},
+		{
+			"paused", weekly, date("2020-10-05", "00:00"), date("2020-10-24", "00:00"), []time.Time{date("2020-10-16", "00:00")},
+			[]time.Time{date("2020-10-09", "09:30"), date("2020-10-23", "09:30")},
+		},
+		{
+			"biweekly", biweekly, date("2020-10-01", "00:00"), date("2020-11-01", "00:00"), nil,
+			[]time.Time{date("2020-10-02", "09:30"), date("2020-10-16", "09:30"), date("2020-10-30", "09:30")},
+		},
+		{
+			"biweekly keeps parity", biweekly, date("2020-12-01", "00:00"), date("2020-12-31", "00:00"), nil,
+			[]time.Time{date("2020-12-11", "09:30"), date("2020-12-25", "09:30")},
+		},
+		{
+			"empty range", weekly, date("2020-10-10", "00:00"), date("2020-10-10", "00:00"), nil,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		ts := tt.rule.Between(tt.from, tt.to, tt.pauses)
+		if len(ts) != len(tt.expected) {
+			t.Errorf("Between %v, expected: %v, got: %v", tt.name, tt.expected, ts)
+			continue
+		}
+		for i := range ts {
+			if !ts[i].Equal(tt.expected[i]) {
+				t.Errorf("Between %v, expected: %v, got: %v", tt.name, tt.expected, ts)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ref
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dayLayout = "060102"
+
+// Format return the reference of the nth document of the day, as PREFIX-YYMMDD-NNNN-C
+// where C is the Luhn check digit of the day and sequence digits
+func Format(prefix string, day time.Time, n int) string {
+	digits := day.Format(dayLayout) + fmt.Sprintf("%04d", n)
+	return fmt.Sprintf("%s-%s-%04d-%d", prefix, day.Format(dayLayout), n, CheckDigit(digits))
+}
+
+// Valid reports whether a reference is well formed and its check digit matches
+func Valid(prefix, r string) bool {
+	parts := strings.Split(r, "-")
+	if len(parts) != 4 || parts[0] != prefix {
+		return false
+	}
+
+	if _, err := time.Parse(dayLayout, parts[1]); err != nil {
+		return false
+	}
+
+	if len(parts[2]) < 4 || !isDigits(parts[2]) || len(parts[3]) != 1 || !isDigits(parts[3]) {
+		return false
+	}
+
+	c, _ := strconv.Atoi(parts[3])
+	return CheckDigit(parts[1]+parts[2]) == c
+}
+
+// CheckDigit return the Luhn check digit of a string of digits, it catches
+// any single mistyped digit and most swaps of adjacent ones
+func CheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,70 @@
+package ref_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/ref"
+)
+
+func TestCheckDigit(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected int
+	}{
+		{"7992739871", 3},
+		{"0", 0},
+		{"2010090042", 0},
+	}
+
+	for _, tt := range tests {
+		if c := ref.CheckDigit(tt.in); c != tt.expected {
+			t.Errorf("CheckDigit on %v, expected: %v, got: %v", tt.in, tt.expected, c)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	day := time.Date(2020, 10, 9, 15, 4, 5, 0, time.UTC)
+
+	var tests = []struct {
+		n        int
+		expected string
+	}{
+		{42, "CMD-201009-0042-0"},
+		{1, "CMD-201009-0001-6"},
+		{12345, "CMD-201009-12345-0"},
+	}
+
+	for _, tt := range tests {
+		r := ref.Format("CMD", day, tt.n)
+		if r != tt.expected {
+			t.Errorf("Format on %v, expected: %v, got: %v", tt.n, tt.expected, r)
+		}
+		if !ref.Valid("CMD", r) {
+			t.Errorf("Valid on formatted %v, expected: true, got: false", r)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected bool
+	}{
+		{"CMD-201009-0042-0", true},
+		{"CMD-201009-0042-2", false},
+		{"CMD-201009-0024-0", false},
+		{"CMD-201009-0043-0", false},
+		{"XYZ-201009-0042-1", false},
+		{"CMD-201339-0042-1", false},
+		{"CMD-201009-42-1", false},
+		{"201009150405", false},
+	}
+
+	for _, tt := range tests {
+		if v := ref.Valid("CMD", tt.in); v != tt.expected {
+			t.Errorf("Valid on %v, expected: %v, got: %v", tt.in, tt.expected, v)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package slot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when no pickup slot exists at the requested time
+	ErrClosed = errors.New("shop is closed at this time")
+	// ErrFull is returned when a pickup slot has reached its capacity
+	ErrFull = errors.New("pickup slot is full")
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Period is an opening period of a day, bounds are minutes since midnight
+type Period struct {
+	Open  int
+	Close int
+}
+
+// Schedule describes opening hours and capacity of pickup slots, zero max means unlimited
+type Schedule struct {
+	Hours     map[time.Weekday][]Period
+	Length    time.Duration
+	MaxOrders int
+	MaxWeight float64
+}
+
+// Slot is a pickup window with the orders already booked in it, weight is in grams
+type Slot struct {
+	Start  time.Time
+	End    time.Time
+	Orders int
+	Weight float64
+}
+
+// Booking is an order pickup of weight grams
+type Booking struct {
+	At     time.Time
+	Weight float64
+}
+
+// ParsePeriod parse an opening period formatted as 08:00-12:30
+func ParsePeriod(s string) (Period, error) {
+	p := Period{}
+
+	bounds := strings.Split(s, "-")
+	if len(bounds) != 2 {
+		return p, fmt.Errorf("period %q must be formatted as 08:00-12:30", s)
+	}
+
+	var err error
+	if p.Open, err = parseClock(bounds[0]); err != nil {
+		return p, err
+	}
+	if p.Close, err = parseClock(bounds[1]); err != nil {
+		return p, err
+	}
+
+	if p.Close <= p.Open {
+		return p, fmt.Errorf("period %q must close after it opens", s)
+	}
+
+	return p, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse time %q. got=%w", s, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// NewSchedule return a schedule from opening periods keyed by lower case weekday name
+func NewSchedule(hours map[string][]string, length time.Duration, maxOrders int, maxWeight float64) (Schedule, error) {
+	s := Schedule{
+		Hours:     make(map[time.Weekday][]Period),
+		Length:    length,
+		MaxOrders: maxOrders,
+		MaxWeight: maxWeight,
+	}
+
+	if length <= 0 {
+		return s, fmt.Errorf("slot length must be positive. got=%v", length)
+	}
+
+	for day, periods := range hours {
+		wd, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return s, fmt.Errorf("unknown weekday %q", day)
+		}
+
+		for _, str := range periods {
+			p, err := ParsePeriod(str)
+			if err != nil {
+				return s, err
+			}
+			s.Hours[wd] = append(s.Hours[wd], p)
+		}
+	}
+
+	return s, nil
+}
+
+// Slots return every slot starting in the given range
+func (s Schedule) Slots(from, to time.Time) []Slot {
+	var slots []Slot
+
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, p := range s.Hours[day.Weekday()] {
+			open := day.Add(time.Duration(p.Open) * time.Minute)
+			close := day.Add(time.Duration(p.Close) * time.Minute)
+
+			for start := open; !start.Add(s.Length).After(close); start = start.Add(s.Length) {
+				if start.Before(from) || !start.Before(to) {
+					continue
+				}
+				slots = append(slots, Slot{Start: start, End: start.Add(s.Length)})
+			}
+		}
+	}
+
+	return slots
+}
+
+// Find return the slot containing the given time or ErrClosed
+func (s Schedule) Find(at time.Time) (Slot, error) {
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+
+	for _, p := range s.Hours[at.Weekday()] {
+		open := day.Add(time.Duration(p.Open) * time.Minute)
+		close := day.Add(time.Duration(p.Close) * time.Minute)
+		if at.Before(open) || !at.Before(close) {
+			continue
+		}
+
+		start := open.Add(at.Sub(open) / s.Length * s.Length)
+		if start.Add(s.Length).After(close) {
+			break
+		}
+		return Slot{Start: start, End: start.Add(s.Length)}, nil
+	}
+
+	return Slot{}, ErrClosed
+}
+
+// Fill count bookings into the slots they fall in
+func Fill(slots []Slot, bookings []Booking) []Slot {
+	for _, b := range bookings {
+		for i := range slots {
+			if !b.At.Before(slots[i].Start) && b.At.Before(slots[i].End) {
+				slots[i].Orders++
+				slots[i].Weight += b.Weight
+				break
+			}
+		}
+	}
+	return slots
+}
+
+// Check return ErrFull if a new booking of weight grams does not fit in the slot
+func (s Schedule) Check(sl Slot, weight float64) error {
+	if s.MaxOrders > 0 && sl.Orders+1 > s.MaxOrders {
+		return ErrFull
+	}
+	if s.MaxWeight > 0 && sl.Weight+weight > s.MaxWeight {
+		return ErrFull
+	}
+	return nil
+}
+
+// Available reports whether one more order can be booked in the slot
+func (s Schedule) Available(sl Slot) bool {
+	return s.Check(sl, 0) == nil
+}
@@ -0,0 +1,126 @@
+package slot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/slot"
+)
+
+// 2020-10-09 is a friday
+func at(hour, min int) time.Time {
+	return time.Date(2020, 10, 9, hour, min, 0, 0, time.UTC)
+}
+
+func schedule(t *testing.T) slot.Schedule {
+	s, err := slot.NewSchedule(map[string][]string{
+		"friday": {"08:00-12:30", "16:00-18:00"},
+	}, 30*time.Minute, 2, 5000)
+	if err != nil {
+		t.Fatalf("NewSchedule failed: %v", err)
+	}
+	return s
+}
+
+func TestParsePeriod(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected slot.Period
+		err      bool
+	}{
+		{"08:00-12:30", slot.Period{Open: 480, Close: 750}, false},
+		{" 16:00 - 18:15 ", slot.Period{Open: 960, Close: 1095}, false},
+		{"12:00-08:00", slot.Period{}, true},
+		{"08:00", slot.Period{}, true},
+		{"8h-12h", slot.Period{}, true},
+	}
+
+	for _, tt := range tests {
+		p, err := slot.ParsePeriod(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParsePeriod on %v, expected error: %v, got: %v", tt.in, tt.err, err)
+			continue
+		}
+		if !tt.err && p != tt.expected {
+			t.Errorf("ParsePeriod on %v, expected: %v, got: %v", tt.in, tt.expected, p)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	s := schedule(t)
+
+	var tests = []struct {
+		in       time.Time
+		expected time.Time
+		err      error
+	}{
+		{at(8, 0), at(8, 0), nil},
+		{at(8, 29), at(8, 0), nil},
+		{at(12, 15), at(12, 0), nil},
+		{at(16, 45), at(16, 30), nil},
+		{at(7, 59), time.Time{}, slot.ErrClosed},
+		{at(12, 30), time.Time{}, slot.ErrClosed},
+		{at(14, 0), time.Time{}, slot.ErrClosed},
+		{at(10, 0).AddDate(0, 0, 1), time.Time{}, slot.ErrClosed},
+	}
+
+	for _, tt := range tests {
+		sl, err := s.Find(tt.in)
+		if err != tt.err {
+			t.Errorf("Find on %v, expected error: %v, got: %v", tt.in, tt.err, err)
+			continue
+		}
+		if err == nil && !sl.Start.Equal(tt.expected) {
+			t.Errorf("Find on %v, expected start: %v, got: %v", tt.in, tt.expected, sl.Start)
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	s := schedule(t)
+
+	var tests = []struct {
+		from     time.Time
+		to       time.Time
+		expected int
+	}{
+		{at(0, 0), at(23, 59), 13},
+		{at(12, 0), at(17, 0), 3},
+		{at(0, 0).AddDate(0, 0, -3), at(0, 0), 0},
+		{at(0, 0), at(0, 0).AddDate(0, 0, 8), 26},
+	}
+
+	for _, tt := range tests {
+		slots := s.Slots(tt.from, tt.to)
+		if len(slots) != tt.expected {
+			t.Errorf("Slots from %v to %v, expected: %v slots, got: %v", tt.from, tt.to, tt.expected, len(slots))
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := schedule(t)
+
+	slots := slot.Fill(s.Slots(at(8, 0), at(9, 0)), []slot.Booking{
+		{At: at(8, 10), Weight: 1000},
+		{At: at(8, 20), Weight: 1500},
+		{At: at(8, 40), Weight: 4000},
+	})
+
+	var tests = []struct {
+		slot     slot.Slot
+		weight   float64
+		expected error
+	}{
+		{slots[0], 100, slot.ErrFull},
+		{slots[1], 1000, nil},
+		{slots[1], 1500, slot.ErrFull},
+	}
+
+	for _, tt := range tests {
+		if err := s.Check(tt.slot, tt.weight); err != tt.expected {
+			t.Errorf("Check on %v with %v, expected: %v, got: %v", tt.slot.Start, tt.weight, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package slug
+
+import "strings"
+
+// accents maps accented latin letters to their plain lowercase form
+var accents = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a",
+	'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'ö': "o", 'õ': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u",
+	'ý': "y", 'ÿ': "y",
+	'œ': "oe", 'æ': "ae",
+}
+
+// Make return the url friendly form of s: lowercase ascii letters and digits
+// separated by single dashes, accents are dropped
+func Make(s string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, c := range strings.ToLower(s) {
+		plain := accents[c]
+		if plain == "" && (c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			plain = string(c)
+		}
+		if plain == "" {
+			dash = true
+			continue
+		}
+
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		dash = false
+		b.WriteString(plain)
+	}
+
+	return b.String()
+}
+
+// Valid reports whether s is already a slug
+func Valid(s string) bool {
+	return s != "" && Make(s) == s
+}
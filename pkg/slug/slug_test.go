@@ -0,0 +1,49 @@
+package slug_test
+
+import (
+	"testing"
+
+	"github.com/valensto/api_apbp/pkg/slug"
+)
+
+func TestMake(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected string
+	}{
+		{"Boeuf", "boeuf"},
+		{"Bœuf", "boeuf"},
+		{"  Volaille & Gibier ", "volaille-gibier"},
+		{"Agneau--de lait", "agneau-de-lait"},
+		{"Préparations", "preparations"},
+		{"Colis 5kg", "colis-5kg"},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		if s := slug.Make(tt.in); s != tt.expected {
+			t.Errorf("Make on %q, expected: %q, got: %q", tt.in, tt.expected, s)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected bool
+	}{
+		{"volaille-gibier", true},
+		{"colis-5kg", true},
+		{"Volaille", false},
+		{"volaille-", false},
+		{"-volaille", false},
+		{"volaille--gibier", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if v := slug.Valid(tt.in); v != tt.expected {
+			t.Errorf("Valid on %q, expected: %v, got: %v", tt.in, tt.expected, v)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package api_apbp
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/order"
+	"github.com/valensto/api_apbp/pkg/pdf"
+)
+
+const preparationTemplate = "web/templates/preparation/sheet.html"
+
+// PreparationSheet lists orders to prepare grouped by pick up hour
+type PreparationSheet struct {
+	From    time.Time
+	To      time.Time
+	Windows []PreparationWindow
+}
+
+// PreparationWindow are the orders picked up within the same hour
+type PreparationWindow struct {
+	Hour   time.Time
+	Orders []JsonOrder
+}
+
+// NewPreparationSheet group orders sorted by pick up time into hourly windows
+func NewPreparationSheet(from, to time.Time, os []order.Order) PreparationSheet {
+	sheet := PreparationSheet{From: from, To: to}
+
+	for _, o := range os {
+		hour := o.RecoveryAt.Truncate(time.Hour)
+		n := len(sheet.Windows)
+		if n == 0 || !sheet.Windows[n-1].Hour.Equal(hour) {
+			sheet.Windows = append(sheet.Windows, PreparationWindow{Hour: hour})
+			n++
+		}
+		sheet.Windows[n-1].Orders = append(sheet.Windows[n-1].Orders, MapOrderToJSON(o))
+	}
+
+	return sheet
+}
+
+// HTML render the sheet with its template
+func (p PreparationSheet) HTML() (*bytes.Buffer, error) {
+	t, err := template.New("sheet.html").Funcs(template.FuncMap{
+		"date": func(t time.Time) string { return t.Format("02/01/2006 15:04") },
+		"hour": func(t time.Time) string { return t.Format("15h04") },
+	}).ParseFiles(preparationTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, p); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// PDF layout in points on A4 pages
+const (
+	sheetMargin = 40.0
+	sheetLine   = 16.0
+	sheetBox    = 9.0
+)
+
+// PDF render the sheet as an A4 document, an order is never split across pages
+func (p PreparationSheet) PDF() (*bytes.Buffer, error) {
+	d := pdf.New(pdf.A4Width, pdf.A4Height)
+	right := pdf.A4Width - sheetMargin
+	y := 0.0
+
+	newPage := func() {
+		d.AddPage()
+		y = pdf.A4Height - sheetMargin
+		d.Text(sheetMargin, y, pdf.Bold, 14, fmt.Sprintf("Préparation du %s au %s", p.From.Format("02/01/2006 15:04"), p.To.Format("02/01/2006 15:04")))
+		d.Text(right-40, y, pdf.Regular, 9, fmt.Sprintf("page %d", d.Pages()))
+		y -= 2 * sheetLine
+	}
+	// ensure start a new page unless h points are left
+	ensure := func(h float64) {
+		if y-h < sheetMargin {
+			newPage()
+		}
+	}
+
+	newPage()
+	if len(p.Windows) == 0 {
+		d.Text(sheetMargin, y, pdf.Regular, 11, "Aucune commande à préparer.")
+	}
+
+	for _, w := range p.Windows {
+		ensure(3 * sheetLine)
+		d.Text(sheetMargin, y, pdf.Bold, 13, fmt.Sprintf("Retrait à %s (%d commandes)", w.Hour.Format("15h04"), len(w.Orders)))
+		d.Line(sheetMargin, y-4, right, y-4)
+		y -= 1.5 * sheetLine
+
+		for _, o := range w.Orders {
+			rows := len(o.ProductsLines) + 2
+			for _, pl := range o.ProductsLines {
+				rows += len(pl.Components)
+			}
+			ensure(float64(rows) * sheetLine)
+
+			d.Rect(sheetMargin, y-1, sheetBox, sheetBox)
+			d.Text(sheetMargin+16, y, pdf.Bold, 11, fmt.Sprintf("%s - %s", o.Ref, o.RecoveryAt.Format("15:04")))
+			if c := o.customer(); c != nil {
+				d.Text(sheetMargin+200, y, pdf.Regular, 11, fmt.Sprintf("%s %s - %s", c.Firstname, c.Lastname, c.Phone))
+			}
+			y -= sheetLine
+
+			for _, pl := range o.ProductsLines {
+				d.Rect(sheetMargin+16, y-1, sheetBox, sheetBox)
+				d.Text(sheetMargin+32, y, pdf.Regular, 10, fmt.Sprintf("%g %s", pl.Quantity, pl.Unit))
+				d.Text(sheetMargin+100, y, pdf.Regular, 10, lineLabel(pl))
+				d.Text(right-60, y, pdf.Regular, 10, pl.Ref)
+				y -= sheetLine
+
+				for _, c := range pl.Components {
+					d.Text(sheetMargin+48, y, pdf.Regular, 9, fmt.Sprintf("%g %s / p", c.Quantity, c.Unit))
+					d.Text(sheetMargin+116, y, pdf.Regular, 9, c.Name)
+					d.Text(right-60, y, pdf.Regular, 9, c.Ref)
+					y -= sheetLine
+				}
+			}
+			y -= sheetLine / 2
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := d.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (o JsonOrder) customer() *JsonUser {
+	if o.RelationShip.Included == nil {
+		return nil
+	}
+	return &o.RelationShip.Included.Customer
+}
+
+// lineLabel return the product name with its preparation option
+func lineLabel(pl ProductLine) string {
+	if pl.Option == nil {
+		return pl.Name
+	}
+	if pl.Option.PrepMinutes > 0 {
+		return fmt.Sprintf("%s - %s (+%d min)", pl.Name, pl.Option.Name, pl.Option.PrepMinutes)
+	}
+	return fmt.Sprintf("%s - %s", pl.Name, pl.Option.Name)
+}
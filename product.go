@@ -8,19 +8,42 @@ import (
 )
 
 type JsonProduct struct {
-	ID          primitive.ObjectID `json:"-"`
-	CreatedAt   time.Time          `json:"created_at,omitempty"`
-	ModifiedAt  time.Time          `json:"modified_at,omitempty"`
-	Ref         string             `json:"ref" validate:"required,len=8,ref"`
-	Name        string             `json:"name" validate:"required"`
-	Category    string             `json:"category,omitempty"`
-	Description string             `json:"description,omitempty"`
-	AUW         float32            `json:"average_unit_weight" validate:"required,numeric"`
+	ID          primitive.ObjectID  `json:"-"`
+	CreatedAt   time.Time           `json:"created_at,omitempty"`
+	ModifiedAt  time.Time           `json:"modified_at,omitempty"`
+	DeletedAt   *time.Time          `json:"deleted_at,omitempty"`
+	Ref         string              `json:"ref" validate:"required,len=8,ref"`
+	Name        string              `json:"name" validate:"required"`
+	CategoryID  *primitive.ObjectID `json:"category_id,omitempty"`
+	Description string              `json:"description,omitempty"`
+	AUW         float32             `json:"average_unit_weight" validate:"required,numeric"`
+	Price       *JsonPrice          `json:"price,omitempty"`
+	Options     []JsonOption        `json:"options,omitempty" validate:"omitempty,unique=Name,dive"`
+	Components  []JsonComponent     `json:"components,omitempty" validate:"omitempty,unique=Ref,dive"`
 }
 
-type JsonCategory struct {
-	Category map[string]string `json:"category"`
-	Products []JsonProduct     `json:"product"`
+type JsonComponent struct {
+	Ref      string  `json:"ref" validate:"required,len=8,ref"`
+	Quantity float32 `json:"quantity" validate:"required,gt=0"`
+	Unit     string  `json:"unit" validate:"required,oneof=gr p"`
+}
+
+type JsonOption struct {
+	Name        string  `json:"name" validate:"required"`
+	Yield       float64 `json:"yield" validate:"required,gt=0,max=100"`
+	PrepMinutes int     `json:"prep_minutes,omitempty" validate:"min=0"`
+}
+
+type JsonPrice struct {
+	PerKg    int64   `json:"per_kg,omitempty" validate:"required_without=PerPiece,omitempty,min=0"`
+	PerPiece int64   `json:"per_piece,omitempty" validate:"required_without=PerKg,omitempty,min=0"`
+	Currency string  `json:"currency" validate:"required,len=3"`
+	VATRate  float64 `json:"vat_rate" validate:"min=0,max=100"`
+}
+
+type JsonCatalog struct {
+	Category *JsonCategory `json:"category"`
+	Products []JsonProduct `json:"product"`
 }
 
 func MapProductToJSON(p *product.Product) JsonProduct {
@@ -28,15 +51,70 @@ func MapProductToJSON(p *product.Product) JsonProduct {
 		ID:          p.ID,
 		CreatedAt:   p.CreatedAt,
 		ModifiedAt:  p.ModifiedAt,
+		DeletedAt:   p.DeletedAt,
 		Ref:         p.Ref,
 		Name:        p.Name,
-		Category:    p.Category,
+		CategoryID:  p.CategoryID,
 		Description: p.Description,
 		AUW:         p.AUW,
+		Price:       MapPriceToJSON(p.Price),
+		Options:     MapOptionsToJSON(p.Options),
+		Components:  MapComponentsToJSON(p.Components),
+	}
+}
+
+func MapComponentsToJSON(cs []product.Component) []JsonComponent {
+	if len(cs) == 0 {
+		return nil
+	}
+
+	components := make([]JsonComponent, len(cs))
+	for i, c := range cs {
+		components[i] = JsonComponent{
+			Ref:      c.Ref,
+			Quantity: c.Quantity,
+			Unit:     c.Unit,
+		}
+	}
+	return components
+}
+
+func MapOptionsToJSON(os []product.Option) []JsonOption {
+	if len(os) == 0 {
+		return nil
+	}
+
+	options := make([]JsonOption, len(os))
+	for i, o := range os {
+		options[i] = *MapOptionToJSON(&o)
+	}
+	return options
+}
+
+func MapOptionToJSON(o *product.Option) *JsonOption {
+	if o == nil {
+		return nil
+	}
+	return &JsonOption{
+		Name:        o.Name,
+		Yield:       o.Yield,
+		PrepMinutes: o.PrepMinutes,
+	}
+}
+
+func MapPriceToJSON(p *product.Price) *JsonPrice {
+	if p == nil {
+		return nil
+	}
+	return &JsonPrice{
+		PerKg:    p.PerKg,
+		PerPiece: p.PerPiece,
+		Currency: p.Currency,
+		VATRate:  p.VATRate,
 	}
 }
 
-func MapCategoryToJSON(p *product.Category) JsonCategory {
+func MapCatalogToJSON(p *product.Catalog) JsonCatalog {
 	products := make([]JsonProduct, len(p.Products))
 	for i, pr := range p.Products {
 		product := JsonProduct{
@@ -45,14 +123,18 @@ func MapCategoryToJSON(p *product.Category) JsonCategory {
 			ModifiedAt:  pr.ModifiedAt,
 			Ref:         pr.Ref,
 			Name:        pr.Name,
+			CategoryID:  pr.CategoryID,
 			Description: pr.Description,
 			AUW:         pr.AUW,
+			Price:       MapPriceToJSON(pr.Price),
+			Options:     MapOptionsToJSON(pr.Options),
+			Components:  MapComponentsToJSON(pr.Components),
 		}
 		products[i] = product
 	}
 
-	return JsonCategory{
-		Category: p.Category,
+	return JsonCatalog{
+		Category: MapCategoryToJSON(p.Category),
 		Products: products,
 	}
 }
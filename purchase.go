@@ -0,0 +1,55 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/purchase"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonPurchase struct {
+	ID         primitive.ObjectID `json:"-"`
+	CreatedAt  time.Time          `json:"created_at,omitempty"`
+	ModifiedAt time.Time          `json:"modified_at,omitempty"`
+	Supplier   primitive.ObjectID `json:"supplier"`
+	Editor     primitive.ObjectID `json:"editor"`
+	Status     string             `json:"status"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	ExpectedAt time.Time          `json:"expected_at"`
+	ReceivedAt *time.Time         `json:"received_at,omitempty"`
+	Lines      []JsonPurchaseLine `json:"lines"`
+}
+
+type JsonPurchaseLine struct {
+	Ref      string   `json:"ref"`
+	Name     string   `json:"name"`
+	Quantity float64  `json:"quantity"`
+	Received *float64 `json:"received,omitempty"`
+}
+
+func MapPurchaseToJSON(p purchase.Purchase) JsonPurchase {
+	lines := make([]JsonPurchaseLine, len(p.Lines))
+	for i, l := range p.Lines {
+		lines[i] = JsonPurchaseLine{
+			Ref:      l.Ref,
+			Name:     l.Name,
+			Quantity: l.Quantity,
+			Received: l.Received,
+		}
+	}
+
+	return JsonPurchase{
+		ID:         p.ID,
+		CreatedAt:  p.CreatedAt,
+		ModifiedAt: p.ModifiedAt,
+		Supplier:   p.Supplier,
+		Editor:     p.Editor,
+		Status:     p.Status,
+		From:       p.From,
+		To:         p.To,
+		ExpectedAt: p.ExpectedAt,
+		ReceivedAt: p.ReceivedAt,
+		Lines:      lines,
+	}
+}
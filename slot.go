@@ -0,0 +1,29 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/pkg/slot"
+)
+
+type JsonSlot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Orders    int       `json:"orders"`
+	Weight    float64   `json:"weight"`
+	MaxOrders int       `json:"max_orders,omitempty"`
+	MaxWeight float64   `json:"max_weight,omitempty"`
+	Available bool      `json:"available"`
+}
+
+func MapSlotToJSON(sl slot.Slot, s slot.Schedule) JsonSlot {
+	return JsonSlot{
+		Start:     sl.Start,
+		End:       sl.End,
+		Orders:    sl.Orders,
+		Weight:    sl.Weight,
+		MaxOrders: s.MaxOrders,
+		MaxWeight: s.MaxWeight,
+		Available: s.Available(sl),
+	}
+}
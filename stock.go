@@ -0,0 +1,50 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/stock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonStock struct {
+	Ref        string    `json:"ref"`
+	Quantity   float64   `json:"quantity"`
+	Reserved   float64   `json:"reserved"`
+	Available  float64   `json:"available"`
+	ModifiedAt time.Time `json:"modified_at,omitempty"`
+}
+
+type JsonMovement struct {
+	ID        primitive.ObjectID  `json:"-"`
+	CreatedAt time.Time           `json:"created_at"`
+	Ref       string              `json:"ref"`
+	Kind      string              `json:"kind"`
+	Quantity  float64             `json:"quantity"`
+	Order     *primitive.ObjectID `json:"order,omitempty"`
+	Editor    primitive.ObjectID  `json:"editor"`
+	Note      string              `json:"note,omitempty"`
+}
+
+func MapStockToJSON(s stock.Stock) JsonStock {
+	return JsonStock{
+		Ref:        s.Ref,
+		Quantity:   s.Quantity,
+		Reserved:   s.Reserved,
+		Available:  s.Available(),
+		ModifiedAt: s.ModifiedAt,
+	}
+}
+
+func MapMovementToJSON(m stock.Movement) JsonMovement {
+	return JsonMovement{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt,
+		Ref:       m.Ref,
+		Kind:      m.Kind,
+		Quantity:  m.Quantity,
+		Order:     m.Order,
+		Editor:    m.Editor,
+		Note:      m.Note,
+	}
+}
@@ -0,0 +1,70 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/subscription"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonSubscription struct {
+	ID             primitive.ObjectID     `json:"-"`
+	CreatedAt      time.Time              `json:"created_at,omitempty"`
+	ModifiedAt     time.Time              `json:"modified_at,omitempty"`
+	DeletedAt      *time.Time             `json:"deleted_at,omitempty"`
+	Customer       primitive.ObjectID     `json:"customer"`
+	Editor         primitive.ObjectID     `json:"editor,omitempty"`
+	ProductsLines  []JsonSubscriptionLine `json:"products"`
+	Rule           JsonRule               `json:"rule"`
+	Pauses         []time.Time            `json:"pauses"`
+	GeneratedUntil *time.Time             `json:"generated_until,omitempty"`
+}
+
+type JsonSubscriptionLine struct {
+	Quantity  float32            `json:"quantity"`
+	Unit      string             `json:"unit"`
+	ProductID primitive.ObjectID `json:"product_id"`
+	Option    string             `json:"option,omitempty"`
+}
+
+type JsonRule struct {
+	Frequency string    `json:"frequency"`
+	Weekday   int       `json:"weekday"`
+	At        string    `json:"at"`
+	Start     time.Time `json:"start"`
+}
+
+func MapSubscriptionToJSON(s subscription.Subscription) JsonSubscription {
+	lines := make([]JsonSubscriptionLine, len(s.ProductsLines))
+	for i, l := range s.ProductsLines {
+		lines[i] = JsonSubscriptionLine{
+			Quantity:  l.Quantity,
+			Unit:      l.Unit,
+			ProductID: l.ProductID,
+			Option:    l.Option,
+		}
+	}
+
+	pauses := s.Pauses
+	if pauses == nil {
+		pauses = []time.Time{}
+	}
+
+	return JsonSubscription{
+		ID:            s.ID,
+		CreatedAt:     s.CreatedAt,
+		ModifiedAt:    s.ModifiedAt,
+		DeletedAt:     s.DeletedAt,
+		Customer:      s.RelationShip.Customer,
+		Editor:        s.RelationShip.Editor,
+		ProductsLines: lines,
+		Rule: JsonRule{
+			Frequency: s.Rule.Frequency,
+			Weekday:   s.Rule.Weekday,
+			At:        s.Rule.At,
+			Start:     s.Rule.Start,
+		},
+		Pauses:         pauses,
+		GeneratedUntil: s.GeneratedUntil,
+	}
+}
@@ -0,0 +1,40 @@
+package api_apbp
+
+import (
+	"time"
+
+	"github.com/valensto/api_apbp/infra/repo/supplier"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JsonSupplier struct {
+	ID         primitive.ObjectID `json:"-"`
+	CreatedAt  time.Time          `json:"created_at,omitempty"`
+	ModifiedAt time.Time          `json:"modified_at,omitempty"`
+	Name       string             `json:"name" validate:"required"`
+	Contact    JsonContact        `json:"contact"`
+	Refs       []string           `json:"refs" validate:"required,unique,min=1,dive,len=8,ref"`
+	LeadTime   int                `json:"lead_time" validate:"min=0"`
+}
+
+type JsonContact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty" validate:"omitempty,email"`
+	Phone string `json:"phone,omitempty"`
+}
+
+func MapSupplierToJSON(s supplier.Supplier) JsonSupplier {
+	return JsonSupplier{
+		ID:         s.ID,
+		CreatedAt:  s.CreatedAt,
+		ModifiedAt: s.ModifiedAt,
+		Name:       s.Name,
+		Contact: JsonContact{
+			Name:  s.Contact.Name,
+			Email: s.Contact.Email,
+			Phone: s.Contact.Phone,
+		},
+		Refs:     s.Refs,
+		LeadTime: s.LeadTime,
+	}
+}
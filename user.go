@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/valensto/api_apbp/infra/repo/user"
+	"github.com/valensto/api_apbp/pkg/mailer"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -18,6 +19,7 @@ func MapUserToJSON(u user.User) JsonUser {
 		ID:         u.ID,
 		CreatedAt:  u.CreatedAt,
 		ModifiedAt: u.ModifiedAt,
+		DeletedAt:  u.DeletedAt,
 		Lastname:   u.Lastname,
 		Firstname:  u.Firstname,
 		Phone:      u.Phone,
@@ -52,3 +54,26 @@ type JsonAddr struct {
 	Postcode   string `json:"postcode"`
 	City       string `json:"city"`
 }
+
+type resetMail struct {
+	Firstname string
+	Token     string
+	URL       string
+}
+
+func (u JsonUser) ResetPasswordMail(token, url string) mailer.Mail {
+	mail := mailer.NewMail()
+
+	data := resetMail{
+		Firstname: u.Firstname,
+		Token:     token,
+		URL:       url,
+	}
+
+	mail.ParseTemplate("web/templates/mail/reset.html", data)
+
+	mail.Subject = "Réinitialisation de votre mot de passe"
+	mail.To = []string{u.Email}
+
+	return mail
+}